@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+//go:embed predefined.h
+var predefinedHeader []byte
+
+const runtimeArchiveName = "libgogogogogo.a"
+
+type buildOptions struct {
+	buildDirname   string
+	runtimePath    string
+	mainPath       string
+	dce            bool
+	lineDirectives bool
+	cacheDirname   string
+	profile        buildProfile
+	toolchain      toolchain
+	dump           dumpOptions
+}
+
+// toolchain configures the C compiler. The flags are added after the ones
+// of the profile, so that they can override them.
+type toolchain struct {
+	cc      string
+	cflags  string
+	ldflags string
+}
+
+func (tc *toolchain) registerFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&tc.cc, "cc", os.Getenv("GOX5_CC"), "C compiler (default: $GOX5_CC, or gcc through ccache when available)")
+	flagSet.StringVar(&tc.cflags, "cflags", os.Getenv("GOX5_CFLAGS"), "additional C compiler flags (default: $GOX5_CFLAGS)")
+	flagSet.StringVar(&tc.ldflags, "ldflags", os.Getenv("GOX5_LDFLAGS"), "additional linker flags (default: $GOX5_LDFLAGS)")
+}
+
+// buildProfile selects how the generated code and the runtime are built.
+// The debug profile keeps the runtime assertions and the undefined behavior
+// sanitizer, while the release profile optimizes and strips them.
+type buildProfile struct {
+	name string
+	lto  bool
+}
+
+const (
+	debugProfile   = "debug"
+	releaseProfile = "release"
+)
+
+func (profile buildProfile) isRelease() bool {
+	return profile.name == releaseProfile
+}
+
+func (profile buildProfile) validate() error {
+	switch profile.name {
+	case debugProfile:
+		if profile.lto {
+			return errors.New("-lto requires -profile=release")
+		}
+		return nil
+	case releaseProfile:
+		return nil
+	}
+	return fmt.Errorf("unknown profile %q, expected %s or %s", profile.name, debugProfile, releaseProfile)
+}
+
+func (profile *buildProfile) registerFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&profile.name, "profile", debugProfile,
+		"build profile: debug keeps runtime checks, release optimizes and strips them")
+	flagSet.BoolVar(&profile.lto, "lto", false, "enable link time optimization, with -profile=release")
+}
+
+func (opts *buildOptions) registerFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&opts.buildDirname, "b", "", "build directory (default: temporary directory)")
+	flagSet.StringVar(&opts.runtimePath, "runtime", os.Getenv("GOX5_RUNTIME"),
+		"runtime archive, or cargo target directory containing it (default: $GOX5_RUNTIME)")
+	flagSet.StringVar(&opts.mainPath, "main", "", "import path of the main package, when several are matched")
+	flagSet.BoolVar(&opts.dce, "dce", true, "emit only the functions reachable from main")
+	flagSet.BoolVar(&opts.lineDirectives, "line-directives", true, "attribute the generated C code to the Go source with #line directives")
+	flagSet.StringVar(&opts.cacheDirname, "cache", defaultCacheDirectory(),
+		"cache directory of generated C and object files, or \"off\" (default: $GOX5_CACHE or the user cache directory)")
+	opts.profile.registerFlags(flagSet)
+	opts.toolchain.registerFlags(flagSet)
+	opts.dump.registerFlags(flagSet)
+}
+
+// cacheOptions describes the options which change the generated files.
+func (opts *buildOptions) cacheOptions() string {
+	return fmt.Sprintf("dce=%t line-directives=%t profile=%s lto=%t cc=%q cflags=%q",
+		opts.dce, opts.lineDirectives, opts.profile.name, opts.profile.lto, opts.toolchain.cc, opts.toolchain.cflags)
+}
+
+// reachableFunctions returns the functions to emit, or nil to emit all of
+// them when dead code elimination is disabled.
+func (opts *buildOptions) reachableFunctions(program *ssa.Program, mainPkg *ssa.Package) map[*ssa.Function]struct{} {
+	if !opts.dce {
+		return nil
+	}
+	return analyzeReachability(program, mainPkg)
+}
+
+// resolveRuntimeArchive returns the absolute path of the runtime archive.
+// The configured path may point at the archive itself, at a directory
+// containing it, or at a cargo target directory, in which case the archive
+// of the selected profile is used.
+func (opts *buildOptions) resolveRuntimeArchive() (string, error) {
+	if err := opts.profile.validate(); err != nil {
+		return "", err
+	}
+	if opts.runtimePath == "" {
+		return "", errors.New("runtime archive not configured: set -runtime or GOX5_RUNTIME")
+	}
+	path, err := filepath.Abs(opts.runtimePath)
+	if err != nil {
+		return "", err
+	}
+	candidates := []string{
+		path,
+		filepath.Join(path, runtimeArchiveName),
+		filepath.Join(path, opts.profile.name, runtimeArchiveName),
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("runtime archive %s not found in %s", runtimeArchiveName, path)
+}
+
+// prepareBuildDirectory creates the build directory and returns it with a
+// cleanup function removing it when it is a temporary one.
+func (opts *buildOptions) prepareBuildDirectory() (string, func(), error) {
+	if opts.buildDirname != "" {
+		if err := os.MkdirAll(opts.buildDirname, 0o755); err != nil {
+			return "", nil, err
+		}
+		return opts.buildDirname, func() {}, nil
+	}
+	dirname, err := ioutil.TempDir("", "cgen-build-")
+	if err != nil {
+		return "", nil, err
+	}
+	return dirname, func() { os.RemoveAll(dirname) }, nil
+}
+
+func writePredefinedHeader(buildDirname string) error {
+	return writeFileIfChanged(filepath.Join(buildDirname, "predefined.h"), predefinedHeader)
+}
+
+// compileProgram generates C code for the program into buildDirname and
+// builds it with make. It returns the path of the produced binary. Files
+// unchanged since a previous build are restored from the cache, and only
+// what changed is recompiled.
+func compileProgram(opts *buildOptions, program *ssa.Program, mainPkg *ssa.Package, sourceFiles map[*types.Package][]string, buildDirname string, runtimeArchive string) (string, error) {
+	cache, err := openBuildCache(opts.cacheDirname, opts.cacheOptions(), sourceFiles)
+	if err != nil {
+		return "", err
+	}
+	if err := writePredefinedHeader(buildDirname); err != nil {
+		return "", err
+	}
+	reachable := opts.reachableFunctions(program, mainPkg)
+	if err := emitProgram(program, mainPkg, reachable, opts, cache, buildDirname, runtimeArchive); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("make", "-C", buildDirname, fmt.Sprintf("-j%d", runtime.NumCPU()))
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		reportCompilerOutput(os.Stderr, output)
+		return "", errors.New("C compilation failed")
+	}
+	if cache != nil {
+		if err := cache.commit(buildDirname); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(buildDirname, binaryName), nil
+}
+
+var (
+	compilerContextPattern    = regexp.MustCompile(`^(\S+\.(?:c|go)): In function '([^']+)':$`)
+	compilerDiagnosticPattern = regexp.MustCompile(`^(\S+\.(?:c|go)):(\d+):(\d+): (fatal error|error|warning|note): (.*)$`)
+)
+
+// reportCompilerOutput prints the diagnostics of the C compiler, annotated
+// with the Go function from which the failing C code was generated.
+func reportCompilerOutput(w io.Writer, output []byte) {
+	mapped := false
+	goFunction := ""
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := compilerContextPattern.FindStringSubmatch(line); m != nil {
+			goFunction = describeCSymbol(m[2])
+			continue
+		}
+		m := compilerDiagnosticPattern.FindStringSubmatch(line)
+		if m == nil || m[4] == "note" {
+			continue
+		}
+		location := fmt.Sprintf("%s:%s:%s", m[1], m[2], m[3])
+		if goFunction != "" {
+			fmt.Fprintf(w, "%s: %s: %s (generated from %s)\n", location, m[4], m[5], goFunction)
+		} else {
+			fmt.Fprintf(w, "%s: %s: %s\n", location, m[4], m[5])
+		}
+		mapped = true
+	}
+	if !mapped {
+		w.Write(output)
+	}
+}
+
+var encodedCharacterPattern = regexp.MustCompile(`_(U[0-9A-F]{4,6}|[0-9A-F]{2})_`)
+
+// decode is the inverse of encode.
+func decode(name string) string {
+	return encodedCharacterPattern.ReplaceAllStringFunc(name, func(s string) string {
+		c, err := strconv.ParseUint(strings.TrimPrefix(s[1:len(s)-1], "U"), 16, 32)
+		if err != nil {
+			return s
+		}
+		return string(rune(c))
+	})
+}
+
+// describeCSymbol explains which Go entity a generated C symbol belongs to.
+func describeCSymbol(symbol string) string {
+	decoded := decode(symbol)
+	decoded = strings.TrimSuffix(decoded, "_return")
+	decoded = strings.TrimSuffix(decoded, "$bound")
+	switch {
+	case strings.HasPrefix(decoded, "f$"):
+		return fmt.Sprintf("Go function %s", strings.TrimPrefix(decoded, "f$"))
+	case strings.HasPrefix(decoded, "b$"):
+		parts := strings.SplitN(decoded, "$", 3)
+		if len(parts) == 3 {
+			return fmt.Sprintf("Go function %s, block %s", parts[2], parts[1])
+		}
+	case strings.HasPrefix(decoded, "i$"):
+		parts := strings.SplitN(decoded, "$", 4)
+		if len(parts) == 4 {
+			return fmt.Sprintf("Go function %s, block %s, instruction %s", parts[3], parts[2], parts[1])
+		}
+	case strings.HasPrefix(decoded, "equal_"):
+		return fmt.Sprintf("equality function of %s", strings.TrimPrefix(decoded, "equal_"))
+	case strings.HasPrefix(decoded, "hash_"):
+		return fmt.Sprintf("hash function of %s", strings.TrimPrefix(decoded, "hash_"))
+	}
+	return fmt.Sprintf("C function %s", decoded)
+}
+
+func copyFile(dst string, src string, perm os.FileMode) error {
+	if srcInfo, err := os.Stat(src); err == nil {
+		if dstInfo, err := os.Stat(dst); err == nil && os.SameFile(srcInfo, dstInfo) {
+			return nil
+		}
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// defaultOutputName names the binary after the main package like go build
+// does, or after the source file when the package was given as files.
+func defaultOutputName(mainPkg *ssa.Package) string {
+	path := mainPkg.Pkg.Path()
+	if path == "command-line-arguments" {
+		if function, ok := mainPkg.Members["main"].(*ssa.Function); ok {
+			filename := mainPkg.Prog.Fset.Position(function.Pos()).Filename
+			return strings.TrimSuffix(filepath.Base(filename), ".go")
+		}
+	}
+	return filepath.Base(path)
+}
+
+func buildCommand(args []string) {
+	flagSet := flag.NewFlagSet("build", flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "usage: cgen build [flags] packages\n")
+		flagSet.PrintDefaults()
+	}
+	var opts buildOptions
+	opts.registerFlags(flagSet)
+	outputName := flagSet.String("o", "", "output binary (default: derived from the package)")
+	flagSet.Parse(args)
+	if flagSet.NArg() < 1 {
+		flagSet.Usage()
+		os.Exit(2)
+	}
+
+	runtimeArchive, err := opts.resolveRuntimeArchive()
+	if err != nil {
+		log.Fatal(err)
+	}
+	program, mainPkg, sourceFiles := loadProgram(flagSet.Args(), opts.mainPath)
+	if err := opts.dump.dumpProgram(os.Stderr, program); err != nil {
+		log.Fatal(err)
+	}
+	if *outputName == "" {
+		*outputName = defaultOutputName(mainPkg)
+	}
+	buildDirname, cleanup, err := opts.prepareBuildDirectory()
+	if err != nil {
+		log.Fatal(err)
+	}
+	binary, err := compileProgram(&opts, program, mainPkg, sourceFiles, buildDirname, runtimeArchive)
+	if err == nil {
+		err = copyFile(*outputName, binary, 0o755)
+	}
+	cleanup()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runCommand(args []string) {
+	flagSet := flag.NewFlagSet("run", flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "usage: cgen run [flags] package [--] [arguments...]\n")
+		flagSet.PrintDefaults()
+	}
+	var opts buildOptions
+	opts.registerFlags(flagSet)
+	flagSet.Parse(args)
+	if flagSet.NArg() < 1 {
+		flagSet.Usage()
+		os.Exit(2)
+	}
+	pattern := flagSet.Arg(0)
+	programArgs := flagSet.Args()[1:]
+	if len(programArgs) > 0 && programArgs[0] == "--" {
+		programArgs = programArgs[1:]
+	}
+
+	runtimeArchive, err := opts.resolveRuntimeArchive()
+	if err != nil {
+		log.Fatal(err)
+	}
+	program, mainPkg, sourceFiles := loadProgram([]string{pattern}, opts.mainPath)
+	if err := opts.dump.dumpProgram(os.Stderr, program); err != nil {
+		log.Fatal(err)
+	}
+	buildDirname, cleanup, err := opts.prepareBuildDirectory()
+	if err != nil {
+		log.Fatal(err)
+	}
+	binary, err := compileProgram(&opts, program, mainPkg, sourceFiles, buildDirname, runtimeArchive)
+	if err != nil {
+		cleanup()
+		log.Fatal(err)
+	}
+
+	cmd := exec.Command(binary, programArgs...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	cleanup()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
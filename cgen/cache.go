@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// buildCache keeps the generated C file and its object file for each package,
+// keyed by a hash of everything the generated code depends on: the compiler
+// itself, the options, the sources of the package and of its dependencies,
+// and the functions of the package which are reachable.
+type buildCache struct {
+	dirname     string
+	salt        []byte
+	sourceFiles map[*types.Package][]string
+
+	mutex      sync.Mutex
+	fileHashes map[string][]byte
+	pending    map[string]string // output name -> key, stored after a successful build
+}
+
+// defaultCacheDirectory returns $GOX5_CACHE, or a directory in the user
+// cache directory.
+func defaultCacheDirectory() string {
+	if dirname := os.Getenv("GOX5_CACHE"); dirname != "" {
+		return dirname
+	}
+	dirname, err := os.UserCacheDir()
+	if err != nil {
+		return "off"
+	}
+	return filepath.Join(dirname, "gogogogogo")
+}
+
+// openBuildCache returns nil when the cache is disabled with "off".
+func openBuildCache(dirname string, options string, sourceFiles map[*types.Package][]string) (*buildCache, error) {
+	if dirname == "" || dirname == "off" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dirname, 0o755); err != nil {
+		return nil, err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	if err := hashFile(hash, executable); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(hash, "options %s\n", options)
+
+	return &buildCache{
+		dirname:     dirname,
+		salt:        hash.Sum(nil),
+		sourceFiles: sourceFiles,
+		fileHashes:  make(map[string][]byte),
+		pending:     make(map[string]string),
+	}, nil
+}
+
+func hashFile(w io.Writer, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (cache *buildCache) fileHash(filename string) []byte {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if hash, ok := cache.fileHashes[filename]; ok {
+		return hash
+	}
+	hash := sha256.New()
+	if err := hashFile(hash, filename); err != nil {
+		fmt.Fprintf(hash, "error %s\n", err)
+	}
+	cache.fileHashes[filename] = hash.Sum(nil)
+	return cache.fileHashes[filename]
+}
+
+// packageKey computes the key of the C file generated for pkg, where
+// functions are the names of the functions emitted for it.
+func (cache *buildCache) packageKey(pkg *types.Package, functions []string) string {
+	hash := sha256.New()
+	hash.Write(cache.salt)
+	fmt.Fprintf(hash, "package %s\n", pkg.Path())
+
+	dependencies := []*types.Package{}
+	foundPackageSet := make(map[*types.Package]struct{})
+	var f func(pkg *types.Package)
+	f = func(pkg *types.Package) {
+		if _, ok := foundPackageSet[pkg]; ok {
+			return
+		}
+		foundPackageSet[pkg] = struct{}{}
+		dependencies = append(dependencies, pkg)
+		for _, imported := range pkg.Imports() {
+			f(imported)
+		}
+	}
+	f(pkg)
+	sort.Slice(dependencies, func(i, j int) bool {
+		return dependencies[i].Path() < dependencies[j].Path()
+	})
+	for _, dependency := range dependencies {
+		fmt.Fprintf(hash, "dependency %s\n", dependency.Path())
+		for _, filename := range cache.sourceFiles[dependency] {
+			fmt.Fprintf(hash, "file %s %x\n", filename, cache.fileHash(filename))
+		}
+	}
+
+	for _, function := range functions {
+		fmt.Fprintf(hash, "function %s\n", function)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// sharedDefinitionKey computes the key of the shared definition, which
+// depends on all the packages of the program.
+func (cache *buildCache) sharedDefinitionKey(mainPath string, packageKeys []string) string {
+	hash := sha256.New()
+	hash.Write(cache.salt)
+	fmt.Fprintf(hash, "main %s\n", mainPath)
+	sorted := append([]string{}, packageKeys...)
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		fmt.Fprintf(hash, "package %s\n", key)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (cache *buildCache) entryPath(key string, suffix string) string {
+	return filepath.Join(cache.dirname, key[:2], key+suffix)
+}
+
+// restore copies the cached C file and object file into the build directory.
+// The object file is marked as newer than its sources so that make keeps it.
+// When the entry is missing, it is recorded to be stored by commit.
+func (cache *buildCache) restore(key string, buildDirname string, outputName string) bool {
+	source, err := ioutil.ReadFile(cache.entryPath(key, ".c"))
+	if err == nil {
+		err = writeFileIfChanged(filepath.Join(buildDirname, outputName), source)
+	}
+	if err == nil {
+		objectName := filepath.Join(buildDirname, outputName+".o")
+		err = copyFile(objectName, cache.entryPath(key, ".o"), 0o644)
+		if err == nil {
+			now := time.Now()
+			err = os.Chtimes(objectName, now, now)
+		}
+	}
+	if err != nil {
+		cache.mutex.Lock()
+		defer cache.mutex.Unlock()
+		cache.pending[outputName] = key
+		return false
+	}
+	return true
+}
+
+// commit stores the files which were generated and compiled in the build
+// directory instead of being restored.
+func (cache *buildCache) commit(buildDirname string) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for outputName, key := range cache.pending {
+		if err := os.MkdirAll(filepath.Dir(cache.entryPath(key, "")), 0o755); err != nil {
+			return err
+		}
+		// the object file is stored last, as its presence makes the entry valid
+		sourceName := filepath.Join(buildDirname, outputName)
+		if err := storeFile(cache.entryPath(key, ".c"), sourceName); err != nil {
+			return err
+		}
+		if err := storeFile(cache.entryPath(key, ".o"), sourceName+".o"); err != nil {
+			return err
+		}
+	}
+	cache.pending = make(map[string]string)
+	return nil
+}
+
+// storeFile copies src to dst through a temporary file, so that concurrent
+// builds never see a partially written entry.
+func storeFile(dst string, src string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	if err := copyFile(tmp.Name(), src, 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// writeFileIfChanged keeps the file, and thus its modification time, when
+// its content is already the expected one, so that make does not rebuild
+// what depends on it.
+func writeFileIfChanged(filename string, content []byte) error {
+	if current, err := ioutil.ReadFile(filename); err == nil && bytes.Equal(current, content) {
+		return nil
+	}
+	return ioutil.WriteFile(filename, content, 0o644)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+type coverageReport struct {
+	MainPackage string              `json:"main_package"`
+	Supported   bool                `json:"supported"`
+	Summary     coverageSummary     `json:"summary"`
+	Functions   []functionCoverage  `json:"functions"`
+	Stubs       []functionCoverage  `json:"stubs"`
+	Builtins    []constructCoverage `json:"builtins"`
+	Conversions []constructCoverage `json:"conversions"`
+}
+
+type coverageSummary struct {
+	Functions              int `json:"functions"`
+	UnsupportedFunctions   int `json:"unsupported_functions"`
+	Stubs                  int `json:"stubs"`
+	Builtins               int `json:"builtins"`
+	UnsupportedBuiltins    int `json:"unsupported_builtins"`
+	Conversions            int `json:"conversions"`
+	UnsupportedConversions int `json:"unsupported_conversions"`
+}
+
+type functionCoverage struct {
+	Name        string   `json:"name"`
+	Package     string   `json:"package,omitempty"`
+	Position    string   `json:"position,omitempty"`
+	Supported   bool     `json:"supported"`
+	Diagnostics []string `json:"diagnostics,omitempty"`
+}
+
+// constructCoverage aggregates every use of a builtin with given operand
+// types, or of a conversion between two types.
+type constructCoverage struct {
+	Construct string   `json:"construct"`
+	Supported bool     `json:"supported"`
+	Count     int      `json:"count"`
+	Positions []string `json:"positions"`
+}
+
+// checkProgram walks the functions reachable from main and tries to emit
+// each of their instructions, without writing any code.
+func checkProgram(program *ssa.Program, mainPkg *ssa.Package) *coverageReport {
+	report := &coverageReport{
+		MainPackage: mainPkg.Pkg.Path(),
+		Supported:   true,
+		Functions:   []functionCoverage{},
+		Stubs:       []functionCoverage{},
+	}
+	builtins := make(map[string]*constructCoverage)
+	conversions := make(map[string]*constructCoverage)
+
+	position := func(pos token.Pos) string {
+		if !pos.IsValid() {
+			return ""
+		}
+		return program.Fset.Position(pos).String()
+	}
+	record := func(constructs map[string]*constructCoverage, construct string, pos token.Pos, supported bool) {
+		coverage, ok := constructs[construct]
+		if !ok {
+			coverage = &constructCoverage{Construct: construct, Supported: true, Positions: []string{}}
+			constructs[construct] = coverage
+		}
+		coverage.Count++
+		coverage.Supported = coverage.Supported && supported
+		if p := position(pos); p != "" {
+			coverage.Positions = append(coverage.Positions, p)
+		}
+	}
+
+	reachable := analyzeReachability(program, mainPkg)
+	functions := make([]*ssa.Function, 0, len(reachable))
+	for function := range reachable {
+		functions = append(functions, function)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].String() < functions[j].String()
+	})
+
+	for _, function := range functions {
+		coverage := functionCoverage{
+			Name:      function.RelString(nil),
+			Position:  position(function.Pos()),
+			Supported: true,
+		}
+		if function.Pkg != nil {
+			coverage.Package = function.Pkg.Pkg.Path()
+		}
+
+		if function.Blocks == nil {
+			if isStubbedFunction(function) {
+				coverage.Supported = false
+				report.Stubs = append(report.Stubs, coverage)
+			}
+			continue
+		}
+
+		diagnostics := newDiagnostics(program.Fset)
+		ctx := Context{
+			stream:        io.Discard,
+			program:       program,
+			latestNameMap: make(map[*ssa.BasicBlock]string),
+			diagnostics:   diagnostics,
+		}
+		func() {
+			defer ctx.recoverUnsupported(function.String(), function.Pos())
+			ctx.emitFunctionVariableStructure(function)
+			for _, basicBlock := range function.Blocks {
+				for _, instruction := range basicBlock.Instrs {
+					probe := ctx
+					probe.diagnostics = newDiagnostics(program.Fset)
+					probe.emitInstruction(instruction)
+					supported := probe.diagnostics.count() == 0
+					for _, d := range probe.diagnostics.sorted() {
+						diagnostics.add(d.message, d.scope, instruction.Pos(), function.Pos())
+					}
+
+					switch instr := instruction.(type) {
+					case *ssa.Call:
+						if builtin, ok := instr.Call.Value.(*ssa.Builtin); ok {
+							record(builtins, describeBuiltinCall(builtin, instr.Call.Args), instr.Pos(), supported)
+						}
+					case *ssa.Convert:
+						construct := fmt.Sprintf("%s -> %s", instr.X.Type(), instr.Type())
+						record(conversions, construct, instr.Pos(), supported)
+					}
+				}
+			}
+		}()
+
+		for _, d := range diagnostics.sorted() {
+			coverage.Diagnostics = append(coverage.Diagnostics, d.String())
+		}
+		coverage.Supported = len(coverage.Diagnostics) == 0
+		if !coverage.Supported {
+			report.Summary.UnsupportedFunctions++
+		}
+		report.Functions = append(report.Functions, coverage)
+	}
+
+	report.Builtins = sortedConstructs(builtins)
+	for _, coverage := range report.Builtins {
+		if !coverage.Supported {
+			report.Summary.UnsupportedBuiltins++
+		}
+	}
+	report.Conversions = sortedConstructs(conversions)
+	for _, coverage := range report.Conversions {
+		if !coverage.Supported {
+			report.Summary.UnsupportedConversions++
+		}
+	}
+	report.Summary.Functions = len(report.Functions)
+	report.Summary.Stubs = len(report.Stubs)
+	report.Summary.Builtins = len(report.Builtins)
+	report.Summary.Conversions = len(report.Conversions)
+	report.Supported = report.Summary.UnsupportedFunctions == 0 && report.Summary.Stubs == 0
+
+	return report
+}
+
+func describeBuiltinCall(builtin *ssa.Builtin, args []ssa.Value) string {
+	argTypes := []string{}
+	for _, arg := range args {
+		argTypes = append(argTypes, arg.Type().String())
+	}
+	return fmt.Sprintf("%s(%s)", builtin.Name(), strings.Join(argTypes, ", "))
+}
+
+func sortedConstructs(constructs map[string]*constructCoverage) []constructCoverage {
+	result := make([]constructCoverage, 0, len(constructs))
+	for _, coverage := range constructs {
+		result = append(result, *coverage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Construct < result[j].Construct
+	})
+	return result
+}
+
+func checkCommand(args []string) {
+	flagSet := flag.NewFlagSet("check", flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "usage: cgen check [flags] packages\n")
+		flagSet.PrintDefaults()
+	}
+	mainPath := flagSet.String("main", "", "import path of the main package, when several are matched")
+	flagSet.Parse(args)
+	if flagSet.NArg() < 1 {
+		flagSet.Usage()
+		os.Exit(2)
+	}
+
+	program, mainPkg, _ := loadProgram(flagSet.Args(), *mainPath)
+	report := checkProgram(program, mainPkg)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+	if !report.Supported {
+		os.Exit(1)
+	}
+}
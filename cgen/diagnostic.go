@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"sort"
+	"sync"
+)
+
+// unsupportedError is raised with panic when code generation meets a Go
+// construct which cannot be translated yet. It is recovered by the nearest
+// emitter that knows the source position, see recoverUnsupported.
+type unsupportedError struct {
+	construct string
+}
+
+func (err unsupportedError) Error() string {
+	return fmt.Sprintf("unsupported: %s", err.construct)
+}
+
+func unsupported(format string, args ...interface{}) {
+	panic(unsupportedError{construct: fmt.Sprintf(format, args...)})
+}
+
+type diagnostic struct {
+	position token.Position
+	scope    string // location used when the position is unknown
+	message  string
+}
+
+func (d diagnostic) String() string {
+	if d.position.IsValid() {
+		return fmt.Sprintf("%s: %s", d.position, d.message)
+	}
+	return fmt.Sprintf("%s: %s", d.scope, d.message)
+}
+
+// diagnostics collects the problems found while emitting the packages of a
+// program. It is shared by the goroutines emitting each package.
+type diagnostics struct {
+	mutex   sync.Mutex
+	fset    *token.FileSet
+	entries map[string]diagnostic
+}
+
+func newDiagnostics(fset *token.FileSet) *diagnostics {
+	return &diagnostics{
+		fset:    fset,
+		entries: make(map[string]diagnostic),
+	}
+}
+
+// add records a diagnostic at the first valid position of positions, or at
+// scope when none of them is known. The same diagnostic is recorded once.
+func (ds *diagnostics) add(message string, scope string, positions ...token.Pos) {
+	d := diagnostic{scope: scope, message: message}
+	for _, pos := range positions {
+		if pos.IsValid() {
+			d.position = ds.fset.Position(pos)
+			break
+		}
+	}
+
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	ds.entries[d.String()] = d
+}
+
+func (ds *diagnostics) count() int {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	return len(ds.entries)
+}
+
+// sorted returns the diagnostics ordered by their positions.
+func (ds *diagnostics) sorted() []diagnostic {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	entries := make([]diagnostic, 0, len(ds.entries))
+	for _, d := range ds.entries {
+		entries = append(entries, d)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		lhs, rhs := entries[i], entries[j]
+		if lhs.position.IsValid() != rhs.position.IsValid() {
+			return lhs.position.IsValid()
+		}
+		if lhs.position.Filename != rhs.position.Filename {
+			return lhs.position.Filename < rhs.position.Filename
+		}
+		if lhs.position.Line != rhs.position.Line {
+			return lhs.position.Line < rhs.position.Line
+		}
+		if lhs.position.Column != rhs.position.Column {
+			return lhs.position.Column < rhs.position.Column
+		}
+		return lhs.String() < rhs.String()
+	})
+	return entries
+}
+
+func (ds *diagnostics) report(w io.Writer) {
+	for _, d := range ds.sorted() {
+		fmt.Fprintln(w, d)
+	}
+}
+
+// recoverUnsupported must be deferred directly. It turns an unsupportedError
+// raised by the emitter into a diagnostic, so that emission continues with
+// the next construct. Other panics are propagated.
+func (ctx *Context) recoverUnsupported(scope string, positions ...token.Pos) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	err, ok := r.(unsupportedError)
+	if !ok || ctx.diagnostics == nil {
+		panic(r)
+	}
+	ctx.diagnostics.add(err.Error(), scope, positions...)
+}
+
+func typePos(typ types.Type) token.Pos {
+	if named, ok := typ.(*types.Named); ok {
+		return named.Obj().Pos()
+	}
+	return token.NoPos
+}
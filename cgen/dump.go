@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"text/tabwriter"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+type dumpOptions struct {
+	ssaPattern string
+	cPattern   string
+}
+
+func (opts *dumpOptions) registerFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&opts.ssaPattern, "dump-ssa", "",
+		"print the SSA of functions matching the regular expression, with the C function holding each instruction")
+	flagSet.StringVar(&opts.cPattern, "dump-c", "",
+		"print the C code generated for functions matching the regular expression")
+}
+
+// dumpProgram prints the functions of the program selected by the dump
+// patterns. Functions are matched by their full name, e.g. "(*pkg/path.T).M".
+func (opts *dumpOptions) dumpProgram(w io.Writer, program *ssa.Program) error {
+	if opts.ssaPattern == "" && opts.cPattern == "" {
+		return nil
+	}
+	var ssaPattern, cPattern *regexp.Regexp
+	var err error
+	if opts.ssaPattern != "" {
+		if ssaPattern, err = regexp.Compile(opts.ssaPattern); err != nil {
+			return fmt.Errorf("invalid -dump-ssa pattern: %w", err)
+		}
+	}
+	if opts.cPattern != "" {
+		if cPattern, err = regexp.Compile(opts.cPattern); err != nil {
+			return fmt.Errorf("invalid -dump-c pattern: %w", err)
+		}
+	}
+
+	ctx := Context{
+		stream:        w,
+		program:       program,
+		latestNameMap: make(map[*ssa.BasicBlock]string),
+		diagnostics:   newDiagnostics(program.Fset), // reported when emitting the program
+	}
+	ctx.traverseFunction(nil, func(function *ssa.Function) {
+		name := function.String()
+		if ssaPattern != nil && ssaPattern.MatchString(name) {
+			ctx.dumpSSA(function)
+		}
+		if cPattern != nil && cPattern.MatchString(name) {
+			ctx.dumpC(function)
+		}
+	})
+	return nil
+}
+
+// dumpSSA prints the instructions of the function, each next to the C
+// function containing the code generated for it. An instruction which
+// switches to another function is followed by the continuation resuming it.
+func (ctx *Context) dumpSSA(function *ssa.Function) {
+	tw := tabwriter.NewWriter(ctx.stream, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "func %s\t   %s\n", function, createFunctionName(function))
+	if function.Blocks == nil {
+		fmt.Fprintf(tw, "    (no body)\t\n")
+	}
+	for _, basicBlock := range function.Blocks {
+		continuation := createBasicBlockName(basicBlock)
+		fmt.Fprintf(tw, "%d: %s\t   %s\n", basicBlock.Index, basicBlock.Comment, continuation)
+		for _, instr := range basicBlock.Instrs {
+			text := instr.String()
+			if value, ok := instr.(ssa.Value); ok && value.Name() != "" {
+				text = fmt.Sprintf("%s = %s", value.Name(), text)
+			}
+			if requireSwitchFunction(instr) {
+				continuation = createInstructionName(instr)
+				fmt.Fprintf(tw, "    %s\t-> %s\n", text, continuation)
+			} else {
+				fmt.Fprintf(tw, "    %s\t   %s\n", text, continuation)
+			}
+		}
+	}
+	fmt.Fprintln(tw)
+	tw.Flush()
+}
+
+// dumpC prints the frame structure and the C functions generated for the
+// function. The C code keeps each SSA instruction as a comment.
+func (ctx *Context) dumpC(function *ssa.Function) {
+	fmt.Fprintf(ctx.stream, "// C code of %s\n", function)
+	ctx.emitFunctionVariableStructure(function)
+	if function.Blocks != nil {
+		ctx.emitContinuationDeclarations(function)
+		ctx.emitFunctionDefinition(function)
+	}
+	fmt.Fprintln(ctx.stream)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"io"
+)
+
+// lineWriter inserts #line directives into the generated file, so that
+// compiler errors, sanitizer reports and debuggers show the Go source. A
+// directive is written only when the Go position changes; as C numbers the
+// lines following it consecutively, the further lines of the code generated
+// for one Go line are attributed to the Go lines after it.
+type lineWriter struct {
+	w          io.Writer
+	outputName string
+	lines      int // lines written to w
+
+	goFilename string
+	goLine     int // line of the Go source of the code being written, or 0
+	lineStart  bool
+
+	// the position given by the latest directive, empty after one for the
+	// generated file
+	directiveFilename string
+	directiveLine     int
+}
+
+func newLineWriter(w io.Writer, outputName string) *lineWriter {
+	return &lineWriter{w: w, outputName: outputName, lineStart: true}
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	for rest := p; len(rest) > 0; {
+		if lw.lineStart && lw.goLine > 0 && (lw.goLine != lw.directiveLine || lw.goFilename != lw.directiveFilename) {
+			if err := lw.writeDirective(); err != nil {
+				return len(p) - len(rest), err
+			}
+		}
+		chunk := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			chunk = rest[:i+1]
+		}
+		n, err := lw.w.Write(chunk)
+		rest = rest[n:]
+		if err != nil {
+			return len(p) - len(rest), err
+		}
+		lw.lineStart = chunk[len(chunk)-1] == '\n'
+		if lw.lineStart {
+			lw.lines++
+		}
+	}
+	return len(p), nil
+}
+
+func (lw *lineWriter) writeDirective() error {
+	var err error
+	if lw.goFilename != lw.directiveFilename {
+		_, err = fmt.Fprintf(lw.w, "#line %d %s\n", lw.goLine, createCStringLiteral(lw.goFilename))
+	} else {
+		_, err = fmt.Fprintf(lw.w, "#line %d\n", lw.goLine)
+	}
+	lw.directiveFilename = lw.goFilename
+	lw.directiveLine = lw.goLine
+	lw.lines++
+	return err
+}
+
+// emitGoLine attributes the following C code to the Go source at pos.
+func (ctx *Context) emitGoLine(pos token.Pos) {
+	lw := ctx.lineWriter
+	if lw == nil || !pos.IsValid() {
+		return
+	}
+	position := ctx.program.Fset.Position(pos)
+	lw.goFilename = position.Filename
+	lw.goLine = position.Line
+}
+
+// emitCLine attributes the following C code to the generated file again.
+func (ctx *Context) emitCLine() {
+	lw := ctx.lineWriter
+	if lw == nil || lw.goLine == 0 {
+		return
+	}
+	lw.goLine = 0
+	lw.directiveFilename = ""
+	lw.directiveLine = 0
+	// the line following the directive
+	fmt.Fprintf(lw, "#line %d %s\n", lw.lines+2, createCStringLiteral(lw.outputName))
+}
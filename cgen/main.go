@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,57 +22,146 @@ import (
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/go/ssa"
 	"golang.org/x/tools/go/ssa/ssautil"
+	"golang.org/x/tools/go/types/typeutil"
 )
 
 func main() {
+	log.SetFlags(0)
+	log.SetPrefix("cgen: ")
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "build":
+			buildCommand(os.Args[2:])
+			return
+		case "run":
+			runCommand(os.Args[2:])
+			return
+		case "check":
+			checkCommand(os.Args[2:])
+			return
+		}
+	}
+
 	filename := flag.String("i", "/dev/stdin", "input file")
 	buildDirname := flag.String("b", "/tmp", "build directory")
+	opts := buildOptions{}
+	flag.StringVar(&opts.runtimePath, "runtime", os.Getenv("GOX5_RUNTIME"),
+		"runtime archive, or cargo target directory containing it (default: $GOX5_RUNTIME)")
+	flag.StringVar(&opts.mainPath, "main", "", "import path of the main package")
+	flag.BoolVar(&opts.dce, "dce", true, "emit only the functions reachable from main")
+	flag.BoolVar(&opts.lineDirectives, "line-directives", true, "attribute the generated C code to the Go source with #line directives")
+	opts.profile.registerFlags(flag.CommandLine)
+	opts.toolchain.registerFlags(flag.CommandLine)
+	opts.dump.registerFlags(flag.CommandLine)
 	flag.Parse()
 
+	runtimeArchive, err := opts.resolveRuntimeArchive()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	prog, mainPkg, _ := loadProgram([]string{*filename}, opts.mainPath)
+
+	if err := opts.dump.dumpProgram(os.Stderr, prog); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := writePredefinedHeader(*buildDirname); err != nil {
+		log.Fatal(err)
+	}
+	if err := emitProgram(prog, mainPkg, opts.reachableFunctions(prog, mainPkg), &opts, nil, *buildDirname, runtimeArchive); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadProgram loads the packages matched by patterns, honouring the go.mod of
+// the current directory, and builds SSA for the selected main package and all
+// of its dependencies. It also returns the source files of each package.
+func loadProgram(patterns []string, mainPath string) (*ssa.Program, *ssa.Package, map[*types.Package][]string) {
 	cfg := packages.Config{Mode: packages.LoadAllSyntax}
-	initPkgs, err := packages.Load(&cfg, *filename)
+	initPkgs, err := packages.Load(&cfg, patterns...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if packages.PrintErrors(initPkgs) > 0 {
+		log.Fatal("failed to load packages")
+	}
+
+	mainPkg, err := selectMainPackage(initPkgs, mainPath)
 	if err != nil {
 		log.Fatal(err)
 	}
-	prog, _ := ssautil.AllPackages(initPkgs, ssa.SanityCheckFunctions)
+
+	sourceFiles := make(map[*types.Package][]string)
+	packages.Visit([]*packages.Package{mainPkg}, nil, func(pkg *packages.Package) {
+		sourceFiles[pkg.Types] = pkg.CompiledGoFiles
+	})
+
+	prog, pkgs := ssautil.AllPackages([]*packages.Package{mainPkg}, ssa.SanityCheckFunctions)
 	prog.Build()
+	return prog, pkgs[0], sourceFiles
+}
 
-	if false {
-		var keywords []string
-		ctx := Context{
-			stream:        nil,
-			program:       prog,
-			latestNameMap: make(map[*ssa.BasicBlock]string),
-		}
-		for _, pkg := range prog.AllPackages() {
-			ctx.traverseFunction(pkg, func(function *ssa.Function) {
-				for _, keyword := range keywords {
-					if strings.Contains(function.Name(), keyword) {
-						function.WriteTo(os.Stderr)
-					}
-				}
-			})
+// selectMainPackage picks the main package among the loaded packages. When
+// mainPath is empty, exactly one of them must be a main package.
+func selectMainPackage(initPkgs []*packages.Package, mainPath string) (*packages.Package, error) {
+	if mainPath != "" {
+		var found *packages.Package
+		packages.Visit(initPkgs, nil, func(pkg *packages.Package) {
+			if pkg.PkgPath == mainPath {
+				found = pkg
+			}
+		})
+		if found == nil {
+			return nil, fmt.Errorf("main package %s is not part of the loaded packages", mainPath)
 		}
+		if found.Name != "main" {
+			return nil, fmt.Errorf("package %s is not a main package", mainPath)
+		}
+		return found, nil
 	}
 
-	emitProgram(prog, *buildDirname)
+	candidates := []*packages.Package{}
+	for _, pkg := range initPkgs {
+		if pkg.Name == "main" {
+			candidates = append(candidates, pkg)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, errors.New("no main package found")
+	case 1:
+		return candidates[0], nil
+	default:
+		paths := []string{}
+		for _, pkg := range candidates {
+			paths = append(paths, pkg.PkgPath)
+		}
+		return nil, fmt.Errorf("several main packages found, select one with -main: %s", strings.Join(paths, ", "))
+	}
 }
 
 type Context struct {
-	stream                *os.File
+	stream                io.Writer
 	program               *ssa.Program
+	mainPackage           *ssa.Package
 	latestNameMap         map[*ssa.BasicBlock]string
 	orderedPackageMembers []ssa.Member
+	diagnostics           *diagnostics
+	reachable             map[*ssa.Function]struct{}
+	stripAssertions       bool
+	lineWriter            *lineWriter
+	methodSignatures      typeutil.Map
 }
 
 func encode(str string) string {
 	buf := ""
 	for _, c := range str {
-		if c >= 0x80 {
-			panic(str)
-		}
 		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
 			buf += string(c)
+		} else if c >= 0x80 {
+			buf += fmt.Sprintf("_U%04X_", c)
 		} else {
 			buf += fmt.Sprintf("_%02X_", c)
 		}
@@ -175,7 +269,11 @@ func createTypeName(typ types.Type) string {
 		case *types.Chan:
 			return fmt.Sprintf("Channel<%s>", f(t.Elem()))
 		case *types.Interface:
-			return fmt.Sprintf("Interface")
+			if t.Empty() {
+				return fmt.Sprintf("Interface")
+			}
+			// distinct from the empty interface for its own type info
+			return fmt.Sprintf("Interface<%s>", typ.String())
 		case *types.Map:
 			k := f(t.Key())
 			v := f(t.Elem())
@@ -206,7 +304,8 @@ func createTypeName(typ types.Type) string {
 				return "IterObject"
 			}
 		}
-		panic(fmt.Sprintf("type not supported: %s", typ.String()))
+		unsupported("type %s", typ)
+		return ""
 	}
 	return encode(f(typ))
 }
@@ -242,7 +341,8 @@ func createRawTypeName(typ types.Type) string {
 	case types.Uintptr:
 		return "uintptr_t"
 	}
-	panic(typ)
+	unsupported("operand of type %s", typ)
+	return ""
 }
 
 func createTypeIdName(typ types.Type) string {
@@ -260,9 +360,22 @@ func createFieldName(field *types.Var, index int) string {
 	return rawFieldName
 }
 
+// assertion returns a runtime check of the generated code, which is left
+// out in the release profile.
+func (ctx *Context) assertion(format string, args ...interface{}) string {
+	if ctx.stripAssertions {
+		return ""
+	}
+	return fmt.Sprintf("\tassert(%s);\n", fmt.Sprintf(format, args...))
+}
+
+func (ctx *Context) emitAssertion(format string, args ...interface{}) {
+	fmt.Fprint(ctx.stream, ctx.assertion(format, args...))
+}
+
 func (ctx *Context) switchFunction(nextFunction string, signature *types.Signature, signatureName string, result string, resumeFunction string, paramAndArgsHandler func()) {
 	fmt.Fprintf(ctx.stream, "StackFrameCommon* next_frame = (StackFrameCommon*)(frame + 1);\n")
-	fmt.Fprintf(ctx.stream, "assert(((uintptr_t)next_frame) %% sizeof(uintptr_t) == 0);\n")
+	ctx.emitAssertion("((uintptr_t)next_frame) %% sizeof(uintptr_t) == 0")
 	fmt.Fprintf(ctx.stream, "*next_frame = (StackFrameCommon){ 0 };\n")
 	fmt.Fprintf(ctx.stream, "next_frame->resume_func = %s;\n", wrapInFunctionObject(resumeFunction))
 	fmt.Fprintf(ctx.stream, "next_frame->prev_stack_pointer = ctx->stack_pointer;\n")
@@ -290,7 +403,7 @@ type paramArgPair struct {
 func (ctx *Context) switchFunctionToCallRuntimeApi(nextFunction string, nextFunctionFrame string, resumeFunction string,
 	resultPtr *string, variableSizeFrameHandler func(), paramArgPairs ...paramArgPair) {
 	fmt.Fprintf(ctx.stream, "%s* next_frame = (%s*)(frame + 1);\n", nextFunctionFrame, nextFunctionFrame)
-	fmt.Fprintf(ctx.stream, "assert(((uintptr_t)next_frame) %% sizeof(uintptr_t) == 0);\n")
+	ctx.emitAssertion("((uintptr_t)next_frame) %% sizeof(uintptr_t) == 0")
 	fmt.Fprintf(ctx.stream, "*next_frame = (%s){ 0 };\n", nextFunctionFrame)
 	fmt.Fprintf(ctx.stream, "next_frame->common.resume_func = %s;\n", wrapInFunctionObject(resumeFunction))
 	fmt.Fprintf(ctx.stream, "next_frame->common.prev_stack_pointer = ctx->stack_pointer;\n")
@@ -310,9 +423,266 @@ func (ctx *Context) switchFunctionToCallRuntimeApi(nextFunction string, nextFunc
 	fmt.Fprintf(ctx.stream, "return %s;\n", wrapInFunctionObject(nextFunction))
 }
 
+// emitNilCheck panics with a runtime error if pointer, or a function, is nil,
+// unless it is known not to be, as the address of a variable or of an element.
+func (ctx *Context) emitNilCheck(pointer ssa.Value) {
+	switch pointer.(type) {
+	case *ssa.Alloc, *ssa.FieldAddr, *ssa.Global, *ssa.IndexAddr:
+		return
+	}
+	fmt.Fprintf(ctx.stream, "if (%s.raw == NULL) {\n", createValueRelName(pointer))
+	ctx.switchFunctionToCallRuntimeApi("gox5_panic_nil_dereference", "StackFramePanicNilDereference", "NULL", nil, nil)
+	fmt.Fprintf(ctx.stream, "}\n")
+}
+
+// createNumericConversion returns x converted to dstType. Out of range
+// conversions from floats to integers, which are undefined in C, give the
+// same results as with gc on amd64; the other conversions are C casts.
+func createNumericConversion(x ssa.Value, dstType *types.Basic) string {
+	raw := fmt.Sprintf("%s.raw", createValueRelName(x))
+	srcType, ok := x.Type().Underlying().(*types.Basic)
+	if !ok || srcType.Info()&types.IsFloat == 0 || dstType.Info()&types.IsInteger == 0 {
+		return raw
+	}
+	switch dstType.Kind() {
+	case types.Int8, types.Int16, types.Uint8, types.Uint16:
+		return fmt.Sprintf("(%s)gox5_float_to_int32(%s)", createRawTypeName(dstType), raw)
+	case types.Int32:
+		return fmt.Sprintf("gox5_float_to_int32(%s)", raw)
+	case types.Int, types.Int64:
+		return fmt.Sprintf("gox5_float_to_int64(%s)", raw)
+	case types.Uint32:
+		return fmt.Sprintf("(uint32_t)gox5_float_to_int64(%s)", raw)
+	case types.Uint, types.Uint64, types.Uintptr:
+		return fmt.Sprintf("gox5_float_to_uint64(%s)", raw)
+	}
+	unsupported("conversion from %s to %s", x.Type(), dstType)
+	return ""
+}
+
+func isInteger(typ types.Type) bool {
+	t, ok := typ.Underlying().(*types.Basic)
+	return ok && t.Info()&types.IsInteger != 0
+}
+
+func isSignedInteger(typ types.Type) bool {
+	return isInteger(typ) && typ.Underlying().(*types.Basic).Info()&types.IsUnsigned == 0
+}
+
+// createWrappingRawTypeName returns the unsigned type on which integers of typ
+// are added, subtracted and multiplied. It is at least as wide as int, so
+// that the operands are not promoted to int, on which overflow is undefined.
+func createWrappingRawTypeName(typ *types.Basic) string {
+	switch typ.Kind() {
+	case types.Int, types.Int64, types.Uint, types.Uint64, types.Uintptr:
+		return "uint64_t"
+	}
+	return "uint32_t"
+}
+
+// createArithmetic returns x op y with the semantics of Go: integers wrap
+// around, and the quotient of the most negative integer by -1 is itself
+// with a remainder of 0. The divisor is checked beforehand by
+// emitDivisorCheck.
+func createArithmetic(op token.Token, x ssa.Value, y ssa.Value) string {
+	lhs := fmt.Sprintf("%s.raw", createValueRelName(x))
+	rhs := fmt.Sprintf("%s.raw", createValueRelName(y))
+	if !isInteger(x.Type()) {
+		return fmt.Sprintf("%s %s %s", lhs, op, rhs)
+	}
+	typ := x.Type().Underlying().(*types.Basic)
+	rawType := createRawTypeName(typ)
+	switch op {
+	case token.ADD, token.SUB, token.MUL:
+		wrappingType := createWrappingRawTypeName(typ)
+		return fmt.Sprintf("(%s)((%s)%s %s (%s)%s)", rawType, wrappingType, lhs, op, wrappingType, rhs)
+	case token.QUO:
+		if isSignedInteger(typ) {
+			return fmt.Sprintf("%s == -1 ? (%s)(0 - (%s)%s) : %s / %s", rhs, rawType, createWrappingRawTypeName(typ), lhs, lhs, rhs)
+		}
+	case token.REM:
+		if isSignedInteger(typ) {
+			return fmt.Sprintf("%s == -1 ? 0 : %s %% %s", rhs, lhs, rhs)
+		}
+	}
+	return fmt.Sprintf("%s %s %s", lhs, op, rhs)
+}
+
+// emitDivisorCheck panics with a runtime error if the integer divisor is 0.
+func (ctx *Context) emitDivisorCheck(divisor ssa.Value) {
+	if c, ok := divisor.(*ssa.Const); ok && constant.Sign(c.Value) != 0 {
+		return
+	}
+	fmt.Fprintf(ctx.stream, "if (%s.raw == 0) {\n", createValueRelName(divisor))
+	ctx.switchFunctionToCallRuntimeApi("gox5_panic_divide", "StackFramePanicDivide", "NULL", nil, nil)
+	fmt.Fprintf(ctx.stream, "}\n")
+}
+
+// containsInterface reports whether comparing or hashing a value of the type
+// may reach an interface, whose dynamic type may not be comparable.
+func containsInterface(typ types.Type) bool {
+	switch t := typ.Underlying().(type) {
+	case *types.Array:
+		return containsInterface(t.Elem())
+	case *types.Interface:
+		return true
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if containsInterface(t.Field(i).Type()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// emitUncomparableCheck panics with a runtime error if the equality or hash
+// functions called since the last check reached an uncomparable value.
+func (ctx *Context) emitUncomparableCheck(hashing bool) {
+	fmt.Fprintf(ctx.stream, "if (gox5_uncomparable_type != NULL) {\n")
+	fmt.Fprintf(ctx.stream, "const TypeInfo *type = gox5_uncomparable_type;\n")
+	fmt.Fprintf(ctx.stream, "gox5_uncomparable_type = NULL;\n")
+	ctx.switchFunctionToCallRuntimeApi("gox5_panic_uncomparable", "StackFramePanicUncomparable", "NULL", nil, nil,
+		paramArgPair{param: "type_id", arg: "(TypeId){.info = type}"},
+		paramArgPair{param: "hashing", arg: fmt.Sprintf("%t", hashing)},
+	)
+	fmt.Fprintf(ctx.stream, "}\n")
+}
+
+// emitHashabilityCheck panics with a runtime error if the map key reaches an
+// interface holding a value whose dynamic type is not comparable.
+func (ctx *Context) emitHashabilityCheck(key ssa.Value) {
+	if !containsInterface(key.Type()) {
+		return
+	}
+	fmt.Fprintf(ctx.stream, "(void)hash_%s(&%s);\n", createTypeName(key.Type()), createValueRelName(key))
+	ctx.emitUncomparableCheck(true)
+}
+
+// emitShiftCountCheck panics with a runtime error if the signed shift count
+// is negative.
+func (ctx *Context) emitShiftCountCheck(count ssa.Value) {
+	if !isSignedInteger(count.Type()) {
+		return
+	}
+	if c, ok := count.(*ssa.Const); ok && constant.Sign(c.Value) >= 0 {
+		return
+	}
+	fmt.Fprintf(ctx.stream, "if (%s.raw < 0) {\n", createValueRelName(count))
+	ctx.switchFunctionToCallRuntimeApi("gox5_panic_shift", "StackFramePanicShift", "NULL", nil, nil)
+	fmt.Fprintf(ctx.stream, "}\n")
+}
+
+// boundsOperand is an index checked against a length, a capacity or another
+// index, which is reported as negative only if it is signed.
+type boundsOperand struct {
+	value  string
+	signed bool
+}
+
+func createBoundsOperand(value ssa.Value) boundsOperand {
+	info := value.Type().Underlying().(*types.Basic).Info()
+	return boundsOperand{
+		value:  fmt.Sprintf("%s.raw", createValueRelName(value)),
+		signed: info&types.IsUnsigned == 0,
+	}
+}
+
+// emitBoundsCheck panics with a runtime error formatted by code unless x < y,
+// or x <= y for slice bounds. As both are compared as unsigned, negative x
+// is out of range too.
+func (ctx *Context) emitBoundsCheck(code string, x boundsOperand, y string) {
+	fails := "gox5_bounds_slice_fails"
+	if code == "BoundsIndex" {
+		fails = "gox5_bounds_index_fails"
+	}
+	fmt.Fprintf(ctx.stream, "if (%s((uint64_t)(%s), (uint64_t)(%s))) {\n", fails, x.value, y)
+	ctx.switchFunctionToCallRuntimeApi("gox5_panic_bounds", "StackFramePanicBounds", "NULL", nil, nil,
+		paramArgPair{param: "x", arg: fmt.Sprintf("(int64_t)(%s)", x.value)},
+		paramArgPair{param: "y", arg: fmt.Sprintf("(intptr_t)(%s)", y)},
+		paramArgPair{param: "signed_x", arg: fmt.Sprintf("%t", x.signed)},
+		paramArgPair{param: "code", arg: code},
+	)
+	fmt.Fprintf(ctx.stream, "}\n")
+}
+
+// createSliceOperandLengths returns the length and the capacity of the
+// operand of a slice expression, i.e. a string, a slice or a pointer to array.
+func createSliceOperandLengths(x ssa.Value) (string, string) {
+	switch t := x.Type().Underlying().(type) {
+	case *types.Basic:
+		length := fmt.Sprintf("%s.raw.len", createValueRelName(x))
+		return length, length
+	case *types.Pointer:
+		length := fmt.Sprintf("%d", t.Elem().Underlying().(*types.Array).Len())
+		return length, length
+	case *types.Slice:
+		return fmt.Sprintf("%s.typed.size", createValueRelName(x)), fmt.Sprintf("%s.typed.capacity", createValueRelName(x))
+	}
+	unsupported("slice of %s", x.Type())
+	return "", ""
+}
+
+// emitSliceBoundsChecks checks the indices of x[i:j:k] in the same order as
+// gc, so that the same bound is reported: k against the capacity, j against
+// k, then i against j. Omitted indices need no check except for the implicit
+// j, which is the length.
+func (ctx *Context) emitSliceBoundsChecks(instr *ssa.Slice) {
+	length, capacity := createSliceOperandLengths(instr.X)
+	_, isSlice := instr.X.Type().Underlying().(*types.Slice)
+
+	if instr.Max != nil {
+		code := "BoundsSlice3Alen"
+		if isSlice {
+			code = "BoundsSlice3Acap"
+		}
+		maxIndex := createBoundsOperand(instr.Max)
+		ctx.emitBoundsCheck(code, maxIndex, capacity)
+		high := length
+		if instr.High != nil {
+			ctx.emitBoundsCheck("BoundsSlice3B", createBoundsOperand(instr.High), maxIndex.value)
+			high = fmt.Sprintf("%s.raw", createValueRelName(instr.High))
+		}
+		if instr.Low != nil {
+			ctx.emitBoundsCheck("BoundsSlice3C", createBoundsOperand(instr.Low), high)
+		}
+		return
+	}
+
+	high := length
+	if instr.High != nil {
+		code := "BoundsSliceAlen"
+		if isSlice {
+			code = "BoundsSliceAcap"
+		}
+		ctx.emitBoundsCheck(code, createBoundsOperand(instr.High), capacity)
+		high = fmt.Sprintf("%s.raw", createValueRelName(instr.High))
+	}
+	if instr.Low != nil {
+		ctx.emitBoundsCheck("BoundsSliceB", createBoundsOperand(instr.Low), high)
+	}
+}
+
+// emitArgumentBuffer packs the arguments of a call made by the runtime into the
+// buffer of the frame. The runtime copies the buffer after the result pointer,
+// so each argument is placed at the offset of its parameter in the signature
+// structure, counted from the first one, where a direct call would store it.
+func (ctx *Context) emitArgumentBuffer(signatureName string, argTypes []string, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(ctx.stream, "next_frame->num_arg_buffer_words = 0;\n")
+		return
+	}
+	base := fmt.Sprintf("offsetof(%s, param0)", signatureName)
+	for i, arg := range args {
+		offset := fmt.Sprintf("offsetof(%s, param%d) - %s", signatureName, i, base)
+		fmt.Fprintf(ctx.stream, "*(%s*)((char*)next_frame->arg_buffer + %s) = %s; // param[%d]\n", argTypes[i], offset, arg, i)
+	}
+	fmt.Fprintf(ctx.stream, "next_frame->num_arg_buffer_words = (sizeof(%s) - %s + sizeof(void*) - 1) / sizeof(void*);\n", signatureName, base)
+}
+
 func (ctx *Context) emitCallCommon(callCommon *ssa.CallCommon, nextFunction string, nextFunctionFrame string, resumeFunction string) {
 	if callCommon.Method != nil {
-		panic("method not supported")
+		unsupported("method call %s", callCommon)
 	}
 
 	var functionObject string
@@ -322,7 +692,7 @@ func (ctx *Context) emitCallCommon(callCommon *ssa.CallCommon, nextFunction stri
 	case ssa.Value:
 		functionObject = createValueRelName(callee)
 	default:
-		panic(fmt.Sprintf("unknown callee: %s, %s, %T, %T", callCommon, callee, callCommon, callee))
+		unsupported("callee %s of type %T", callee, callee)
 	}
 
 	signature := callCommon.Value.Type().Underlying().(*types.Signature)
@@ -339,22 +709,46 @@ func (ctx *Context) emitCallCommon(callCommon *ssa.CallCommon, nextFunction stri
 
 	ctx.switchFunctionToCallRuntimeApi(nextFunction, nextFunctionFrame, resumeFunction, nil,
 		func() {
-			fmt.Fprintf(ctx.stream, "intptr_t num_arg_buffer_words = 0;\n")
-			for i, arg := range callCommon.Args {
-				argValue := createValueRelName(arg)
-				argType := createTypeName(arg.Type())
-				argPtr := fmt.Sprintf("ptr%d", i)
-				fmt.Fprintf(ctx.stream, "%s* %s = (void*)&next_frame->arg_buffer[num_arg_buffer_words]; // param[%d]\n", argType, argPtr, i)
-				fmt.Fprintf(ctx.stream, "*%s = %s;\n", argPtr, argValue)
-				fmt.Fprintf(ctx.stream, "num_arg_buffer_words += sizeof(%s) / sizeof(next_frame->arg_buffer[0]);\n", argType)
-			}
-			fmt.Fprintf(ctx.stream, "next_frame->num_arg_buffer_words = num_arg_buffer_words;\n")
+			argTypes := make([]string, 0)
+			args := make([]string, 0)
+			for _, arg := range callCommon.Args {
+				argTypes = append(argTypes, createTypeName(arg.Type()))
+				args = append(args, createValueRelName(arg))
+			}
+			ctx.emitArgumentBuffer(createSignatureName(signature, false, false), argTypes, args)
 		},
 		paramArgPair{param: "function_object", arg: functionObject},
 		paramArgPair{param: "result_size", arg: resultSize},
 	)
 }
 
+// createInterfaceReceiverWord returns the word passed as the receiver of a
+// method invoked through iface. It is NULL for a nil interface, which the
+// runtime reports as a nil dereference.
+func createInterfaceReceiverWord(iface ssa.Value) string {
+	name := createValueRelName(iface)
+	return fmt.Sprintf("(%s.receiver == NULL ? NULL : *(void**)(%s.receiver))", name, name)
+}
+
+// methodSlot returns the index of the invoked method in the itabs of the
+// interface, whose slots are in the order of the methods of the interface.
+func methodSlot(callCommon *ssa.CallCommon) int {
+	iface := callCommon.Value.Type().Underlying().(*types.Interface)
+	for i := 0; i < iface.NumMethods(); i++ {
+		if iface.Method(i).Id() == callCommon.Method.Id() {
+			return i
+		}
+	}
+	panic(fmt.Sprintf("method %s not found in %s", callCommon.Method, iface))
+}
+
+// createInterfaceMethod returns the method invoked through the interface, or
+// NULL for a nil interface, which the runtime reports as a nil dereference.
+func createInterfaceMethod(callCommon *ssa.CallCommon) string {
+	iface := createValueRelName(callCommon.Value)
+	return fmt.Sprintf("(%s.itab == NULL ? (FunctionObject){NULL} : %s.itab[%d])", iface, iface, methodSlot(callCommon))
+}
+
 func (ctx *Context) emitCallCommonForMethod(callCommon *ssa.CallCommon, nextFunction string, nextFunctionFrame string, resumeFunction string) {
 	if callCommon.Method == nil {
 		panic("only method supported")
@@ -374,26 +768,23 @@ func (ctx *Context) emitCallCommonForMethod(callCommon *ssa.CallCommon, nextFunc
 
 	ctx.switchFunctionToCallRuntimeApi(nextFunction, nextFunctionFrame, resumeFunction, nil,
 		func() {
-			receiver := fmt.Sprintf("*(void**)(%s.receiver)", createValueRelName(callCommon.Value))
-			fmt.Fprintf(ctx.stream, "next_frame->arg_buffer[0] = %s; // receiver: %s\n", receiver, signature.Recv())
-			fmt.Fprintf(ctx.stream, "intptr_t num_arg_buffer_words = 1;\n")
-			for i, arg := range callCommon.Args {
-				argValue := createValueRelName(arg)
-				argType := createTypeName(arg.Type())
-				argPtr := fmt.Sprintf("ptr%d", i)
-				fmt.Fprintf(ctx.stream, "%s* %s = (void*)&next_frame->arg_buffer[num_arg_buffer_words]; // param[%d]\n", argType, argPtr, i)
-				fmt.Fprintf(ctx.stream, "*%s = %s;\n", argPtr, argValue)
-				fmt.Fprintf(ctx.stream, "num_arg_buffer_words += sizeof(%s) / sizeof(next_frame->arg_buffer[0]);\n", argType)
-			}
-			fmt.Fprintf(ctx.stream, "next_frame->num_arg_buffer_words = num_arg_buffer_words;\n")
+			argTypes := []string{"void*"}
+			args := []string{createInterfaceReceiverWord(callCommon.Value)}
+			for _, arg := range callCommon.Args {
+				argTypes = append(argTypes, createTypeName(arg.Type()))
+				args = append(args, createValueRelName(arg))
+			}
+			ctx.emitArgumentBuffer(createSignatureName(signature, false, true), argTypes, args)
 		},
 		paramArgPair{param: "interface", arg: fmt.Sprintf("&%s", createValueRelName(callCommon.Value))},
-		paramArgPair{param: "method_name", arg: fmt.Sprintf("(StringObject){\"%s\"}", callCommon.Method.Name())},
+		paramArgPair{param: "method", arg: createInterfaceMethod(callCommon)},
 		paramArgPair{param: "result_size", arg: resultSize},
 	)
 }
 
 func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
+	defer ctx.recoverUnsupported(instruction.Parent().String(), instruction.Pos(), instruction.Parent().Pos())
+	ctx.emitGoLine(instruction.Pos())
 	fmt.Fprintf(ctx.stream, "\t// %T (%s): %s\n", instruction, instruction.Parent(), instruction)
 	fmt.Fprintf(ctx.stream, "\t{\n")
 	switch instr := instruction.(type) {
@@ -420,9 +811,16 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		case token.EQL, token.NEQ:
 			equalFunc := fmt.Sprintf("equal_%s", createTypeName(instr.X.Type()))
 			fmt.Fprintf(ctx.stream, "bool raw = %s(&%s, &%s) %s true;", equalFunc, createValueRelName(instr.X), createValueRelName(instr.Y), instr.Op)
+			if containsInterface(instr.X.Type()) {
+				ctx.emitUncomparableCheck(false)
+			}
 			raw = "raw"
 		case token.LSS, token.LEQ, token.GTR, token.GEQ:
-			raw = fmt.Sprintf("%s.raw %s %s.raw", createValueRelName(instr.X), instr.Op.String(), createValueRelName(instr.Y))
+			if t, ok := instr.X.Type().Underlying().(*types.Basic); ok && t.Info()&types.IsString != 0 {
+				raw = fmt.Sprintf("gox5_string_compare(%s, %s) %s 0", createValueRelName(instr.X), createValueRelName(instr.Y), instr.Op)
+			} else {
+				raw = fmt.Sprintf("%s.raw %s %s.raw", createValueRelName(instr.X), instr.Op.String(), createValueRelName(instr.Y))
+			}
 		case token.ADD:
 			if t, ok := instr.Type().Underlying().(*types.Basic); ok && t.Kind() == types.String {
 				result := createValueRelName(instr)
@@ -432,8 +830,15 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 				)
 				needToCallRuntimeApi = true
 			} else {
-				raw = fmt.Sprintf("%s.raw %s %s.raw", createValueRelName(instr.X), instr.Op.String(), createValueRelName(instr.Y))
+				raw = createArithmetic(instr.Op, instr.X, instr.Y)
 			}
+		case token.SUB, token.MUL:
+			raw = createArithmetic(instr.Op, instr.X, instr.Y)
+		case token.QUO, token.REM:
+			if isInteger(instr.Type()) {
+				ctx.emitDivisorCheck(instr.Y)
+			}
+			raw = createArithmetic(instr.Op, instr.X, instr.Y)
 		case token.SHL:
 			var unsignedRawType string
 			switch instr.Type().Underlying().(*types.Basic).Kind() {
@@ -446,10 +851,7 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 			}
 			fmt.Fprintf(ctx.stream, "%s unsignedLhs = (%s)(%s.raw);\n", unsignedRawType, unsignedRawType, createValueRelName(instr.X))
 			fmt.Fprintf(ctx.stream, "%s rhs = %s.raw;\n", createRawTypeName(instr.Y.Type()), createValueRelName(instr.Y))
-			switch instr.Y.Type().Underlying().(*types.Basic).Kind() {
-			case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
-				fmt.Fprintln(ctx.stream, "assert(rhs>=0);")
-			}
+			ctx.emitShiftCountCheck(instr.Y)
 			raw = "(((size_t)rhs) < sizeof(unsignedLhs) * 8) ? (unsignedLhs << rhs) : 0"
 		case token.SHR:
 			var unsignedRawType string
@@ -470,10 +872,7 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 			}
 			fmt.Fprintf(ctx.stream, "%s unsignedLhs = (%s)(%s.raw);\n", unsignedRawType, unsignedRawType, createValueRelName(instr.X))
 			fmt.Fprintf(ctx.stream, "%s rhs = %s.raw;\n", createRawTypeName(instr.Y.Type()), createValueRelName(instr.Y))
-			switch instr.Y.Type().Underlying().(*types.Basic).Kind() {
-			case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
-				fmt.Fprintln(ctx.stream, "assert(rhs>=0);")
-			}
+			ctx.emitShiftCountCheck(instr.Y)
 			raw = fmt.Sprintf("((size_t)rhs) < %s ? (%s) : (%s)", bitLen, calcExpr, overflowExpr)
 		default:
 			raw = fmt.Sprintf("%s.raw %s %s.raw", createValueRelName(instr.X), instr.Op.String(), createValueRelName(instr.Y))
@@ -485,30 +884,19 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 	case *ssa.Call:
 		callCommon := instr.Common()
 		if callCommon.Method != nil {
-			signature := callCommon.Method.Type().Underlying().(*types.Signature)
-			result_ptr := "NULL"
-			if signature.Results().Len() > 0 {
-				result_ptr = fmt.Sprintf("&%s", createValueRelName(instr))
-			}
-			ctx.switchFunctionToCallRuntimeApi("gox5_interface_invoke", "StackFrameInterfaceInvoke", createInstructionName(instr), nil,
-				func() {
-					receiver := fmt.Sprintf("*(void**)(%s.receiver)", createValueRelName(callCommon.Value))
-					fmt.Fprintf(ctx.stream, "next_frame->arg_buffer[0] = %s; // receiver: %s\n", receiver, signature.Recv())
-					fmt.Fprintf(ctx.stream, "intptr_t num_arg_buffer_words = 1;\n")
-					for i, arg := range callCommon.Args {
-						argValue := createValueRelName(arg)
-						argType := createTypeName(arg.Type())
-						argPtr := fmt.Sprintf("ptr%d", i)
-						fmt.Fprintf(ctx.stream, "%s* %s = (void*)&next_frame->arg_buffer[num_arg_buffer_words]; // param[%d]\n", argType, argPtr, i)
-						fmt.Fprintf(ctx.stream, "*%s = %s;\n", argPtr, argValue)
-						fmt.Fprintf(ctx.stream, "num_arg_buffer_words += sizeof(%s) / sizeof(next_frame->arg_buffer[0]);\n", argType)
-					}
-					fmt.Fprintf(ctx.stream, "next_frame->num_arg_buffer_words = num_arg_buffer_words;\n")
-				},
-				paramArgPair{param: "result_ptr", arg: result_ptr},
-				paramArgPair{param: "interface", arg: fmt.Sprintf("&%s", createValueRelName(callCommon.Value))},
-				paramArgPair{param: "method_name", arg: fmt.Sprintf("(StringObject){\"%s\"}", callCommon.Method.Name())},
-			)
+			iface := createValueRelName(callCommon.Value)
+			fmt.Fprintf(ctx.stream, "if (%s.itab == NULL) {\n", iface)
+			ctx.switchFunctionToCallRuntimeApi("gox5_panic_nil_dereference", "StackFramePanicNilDereference", "NULL", nil, nil)
+			fmt.Fprintf(ctx.stream, "}\n")
+			nextFunction := fmt.Sprintf("%s.itab[%d]", iface, methodSlot(callCommon))
+			signature := callCommon.Signature()
+			signatureName := createSignatureName(signature, false, true)
+			ctx.switchFunction(nextFunction, signature, signatureName, createValueRelName(instr), createInstructionName(instr), func() {
+				fmt.Fprintf(ctx.stream, "signature->param0 = *(void**)%s.receiver; // receiver: %s\n", iface, signature.Recv())
+				for i, arg := range callCommon.Args {
+					fmt.Fprintf(ctx.stream, "signature->param%d = %s; // %s\n", i+1, createValueRelName(arg), signature.Params().At(i))
+				}
+			})
 		} else {
 			switch callee := callCommon.Value.(type) {
 			case *ssa.Builtin:
@@ -544,6 +932,9 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 					}
 
 				case "cap":
+					if _, ok := callCommon.Args[0].Type().Underlying().(*types.Slice); !ok {
+						unsupported("cap of %s", callCommon.Args[0].Type())
+					}
 					result := createValueRelName(instr)
 					ctx.switchFunctionToCallRuntimeApi("gox5_slice_capacity", "StackFrameSliceCapacity", createInstructionName(instr), &result, nil,
 						paramArgPair{param: "slice", arg: fmt.Sprintf("%s.raw", createValueRelName(callCommon.Args[0]))},
@@ -581,6 +972,7 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 					)
 
 				case "delete":
+					ctx.emitHashabilityCheck(callCommon.Args[1])
 					ctx.switchFunctionToCallRuntimeApi("gox5_map_delete", "StackFrameMapDelete", createInstructionName(instr), nil, nil,
 						paramArgPair{param: "map", arg: fmt.Sprintf("%s.raw", createValueRelName(callCommon.Args[0]))},
 						paramArgPair{param: "key", arg: fmt.Sprintf("&%s", createValueRelName(callCommon.Args[1]))},
@@ -602,12 +994,10 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 					case *types.Basic:
 						switch t.Kind() {
 						case types.String:
-							result := createValueRelName(instr)
-							ctx.switchFunctionToCallRuntimeApi("gox5_string_length", "StackFrameStringLength", createInstructionName(instr), &result, nil,
-								paramArgPair{param: "string", arg: createValueRelName(callCommon.Args[0])},
-							)
+							raw := fmt.Sprintf("(intptr_t)%s.raw.len", createValueRelName(callCommon.Args[0]))
+							fmt.Fprintf(ctx.stream, "%s = %s;\n", createValueRelName(instr), wrapInObject(raw, instr.Type()))
 						default:
-							panic(fmt.Sprintf("unsuported argument for len: %s (%s)", callCommon.Args[0], t))
+							unsupported("len of %s", t)
 						}
 					case *types.Map:
 						result := createValueRelName(instr)
@@ -620,7 +1010,7 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 							paramArgPair{param: "slice", arg: fmt.Sprintf("%s.raw", createValueRelName(callCommon.Args[0]))},
 						)
 					default:
-						panic(fmt.Sprintf("unsuported argument for len: %s", callCommon.Args[0]))
+						unsupported("len of %s", callCommon.Args[0].Type())
 					}
 
 				case "print", "println":
@@ -628,19 +1018,20 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 						func() {
 							for i, arg := range callCommon.Args {
 								var format string
-								field := "as_integer"
-								data0 := fmt.Sprintf("%s.raw", createValueRelName(arg))
-								data1 := "0"
-								switch t := arg.Type().(type) {
+								value := createValueRelName(arg)
+								type printData struct {
+									field string
+									value string
+								}
+								data := []printData{{"as_integer", fmt.Sprintf("%s.raw", value)}}
+								switch t := arg.Type().Underlying().(type) {
 								case *types.Basic:
 									switch t.Kind() {
 									case types.Bool:
 										format = "b"
 									case types.Complex64, types.Complex128:
 										format = "i"
-										field = "as_float"
-										data0 = fmt.Sprintf("creal(%s.raw)", createValueRelName(arg))
-										data1 = fmt.Sprintf("cimag(%s.raw)", createValueRelName(arg))
+										data = []printData{{"as_float", fmt.Sprintf("creal(%s.raw)", value)}, {"as_float", fmt.Sprintf("cimag(%s.raw)", value)}}
 									case types.Int:
 										format = "ld"
 									case types.Int8, types.Int16, types.Int32:
@@ -657,23 +1048,39 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 										format = "lu"
 									case types.Float32, types.Float64:
 										format = "f"
-										field = "as_float"
+										data = []printData{{"as_float", fmt.Sprintf("%s.raw", value)}}
 									case types.String:
 										format = "s"
-										field = "as_pointer"
+										data = []printData{{"as_pointer", fmt.Sprintf("%s.raw.ptr", value)}, {"as_integer", fmt.Sprintf("%s.raw.len", value)}}
 									case types.UnsafePointer:
 										format = "p"
-										field = "as_pointer"
+										data = []printData{{"as_pointer", fmt.Sprintf("%s.raw", value)}}
 									default:
-										panic(fmt.Sprintf("%s, %s (%T)", arg, t, t))
+										unsupported("%s of %s", callee.Name(), t)
+									}
+								case *types.Pointer, *types.Signature:
+									format = "p"
+									data = []printData{{"as_pointer", fmt.Sprintf("%s.raw", value)}}
+								case *types.Chan, *types.Map:
+									format = "p"
+									data = []printData{{"as_pointer", fmt.Sprintf("%s.raw.raw", value)}}
+								case *types.Slice:
+									format = "S"
+									data = []printData{
+										{"as_integer", fmt.Sprintf("%s.raw.size", value)},
+										{"as_integer", fmt.Sprintf("%s.raw.capacity", value)},
+										{"as_pointer", fmt.Sprintf("%s.raw.addr", value)},
 									}
+								case *types.Interface:
+									format = "I"
+									data = []printData{{"as_pointer", fmt.Sprintf("%s.type_id.info", value)}, {"as_pointer", fmt.Sprintf("%s.receiver", value)}}
 								default:
-									fmt.Fprintf(ctx.stream, "assert(false); // not supported\n")
-									continue
+									unsupported("%s of %s", callee.Name(), arg.Type())
 								}
 								fmt.Fprintf(ctx.stream, `next_frame->entry_buffer[%d].format = "%%%s";`, i, format)
-								fmt.Fprintf(ctx.stream, "next_frame->entry_buffer[%d].data[0].%s = %s;\n", i, field, data0)
-								fmt.Fprintf(ctx.stream, "next_frame->entry_buffer[%d].data[1].%s = %s;\n", i, field, data1)
+								for j, d := range data {
+									fmt.Fprintf(ctx.stream, "next_frame->entry_buffer[%d].data[%d].%s = %s;\n", i, j, d.field, d.value)
+								}
 							}
 						},
 						paramArgPair{param: "packs", arg: fmt.Sprintf("%t", callee.Name() == "print")},
@@ -702,11 +1109,12 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 					)
 
 				default:
-					panic(fmt.Sprintf("unsuported builtin function: %s", callee.Name()))
+					unsupported("builtin function %s", callee.Name())
 				}
 				fmt.Fprintf(ctx.stream, "\treturn %s;\n", wrapInFunctionObject(createInstructionName(instr)))
 
 			default:
+				ctx.emitNilCheck(callee)
 				nextFunction := createValueRelName(callee)
 				signature := callCommon.Value.Type().Underlying().(*types.Signature)
 				signatureName := createSignatureName(signature, false, false)
@@ -728,7 +1136,18 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		}
 
 	case *ssa.ChangeInterface:
-		fmt.Fprintf(ctx.stream, "%s = %s;\n", createValueRelName(instr), createValueRelName(instr.X))
+		result := createValueRelName(instr)
+		if changesItab(instr) {
+			ctx.switchFunctionToCallRuntimeApi("gox5_interface_change", "StackFrameInterfaceChange", createInstructionName(instr), &result, nil,
+				paramArgPair{param: "interface", arg: fmt.Sprintf("&%s", createValueRelName(instr.X))},
+				paramArgPair{param: "to_type", arg: wrapInTypeId(instr.Type())},
+			)
+		} else {
+			fmt.Fprintf(ctx.stream, "%s = %s;\n", result, createValueRelName(instr.X))
+			if instr.Type().Underlying().(*types.Interface).Empty() {
+				fmt.Fprintf(ctx.stream, "%s.itab = NULL;\n", result)
+			}
+		}
 
 	case *ssa.ChangeType:
 		s := wrapInObject(fmt.Sprintf("%s.raw", createValueRelName(instr.X)), instr.Type())
@@ -760,13 +1179,13 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 								paramArgPair{param: "rune_slice", arg: arg},
 							)
 						default:
-							panic(fmt.Sprintf("%s, %s, %s (%T)", instr, srcType, elemType, elemType))
+							unsupported("conversion from %s to %s", instr.X.Type(), instr.Type())
 						}
 					} else {
-						panic(fmt.Sprintf("%s, %s, %s (%T)", instr, srcType, elemType, elemType))
+						unsupported("conversion from %s to %s", instr.X.Type(), instr.Type())
 					}
 				default:
-					panic(fmt.Sprintf("%s, %s (%T)", instr, srcType, srcType))
+					unsupported("conversion from %s to %s", instr.X.Type(), instr.Type())
 				}
 
 			case types.Uintptr:
@@ -774,7 +1193,7 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 				if srcType, ok := instr.X.Type().Underlying().(*types.Basic); ok && srcType.Kind() == types.UnsafePointer {
 					raw = fmt.Sprintf("(uintptr_t)%s.raw", createValueRelName(instr.X))
 				} else {
-					raw = fmt.Sprintf("%s.raw", createValueRelName(instr.X))
+					raw = createNumericConversion(instr.X, dstType)
 				}
 				fmt.Fprintf(ctx.stream, "%s = %s;\n", createValueRelName(instr), wrapInObject(raw, instr.Type()))
 
@@ -788,7 +1207,7 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 				fmt.Fprintf(ctx.stream, "%s = %s;\n", createValueRelName(instr), wrapInObject(raw, instr.Type()))
 
 			default:
-				raw := fmt.Sprintf("%s.raw", createValueRelName(instr.X))
+				raw := createNumericConversion(instr.X, dstType)
 				fmt.Fprintf(ctx.stream, "%s = %s;\n", createValueRelName(instr), wrapInObject(raw, instr.Type()))
 			}
 
@@ -798,11 +1217,11 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 			case types.Byte, types.Rune:
 				// valid conversion
 			default:
-				panic(instr.String())
+				unsupported("conversion from %s to %s", instr.X.Type(), instr.Type())
 			}
 			srcType := instr.X.Type().Underlying().(*types.Basic)
 			if srcType.Kind() != types.String {
-				panic(instr.String())
+				unsupported("conversion from %s to %s", instr.X.Type(), instr.Type())
 			}
 			result := fmt.Sprintf("%s.raw", createValueRelName(instr))
 			ctx.switchFunctionToCallRuntimeApi("gox5_slice_from_string", " StackFrameSliceFromString", createInstructionName(instr), &result, nil,
@@ -834,6 +1253,7 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		fmt.Fprintf(ctx.stream, "%s = val;\n", createValueRelName(instr))
 
 	case *ssa.FieldAddr:
+		ctx.emitNilCheck(instr.X)
 		index := instr.Field
 		name := createFieldName(instr.X.Type().Underlying().(*types.Pointer).Elem().Underlying().(*types.Struct).Field(index), index)
 		fmt.Fprintf(ctx.stream, "%s* raw = &(%s.raw->%s);\n", createTypeName(instr.Type().(*types.Pointer).Elem()), createValueRelName(instr.X), name)
@@ -843,9 +1263,10 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		fmt.Fprintf(ctx.stream, "uintptr_t index = %s.raw;\n", createValueRelName(instr.Index))
 		switch t := instr.X.Type().(type) {
 		case *types.Array:
+			ctx.emitBoundsCheck("BoundsIndex", createBoundsOperand(instr.Index), fmt.Sprintf("%d", t.Len()))
 			fmt.Fprintf(ctx.stream, "%s val = %s.raw[index];\n", createTypeName(t.Elem()), createValueRelName(instr.X))
 		default:
-			panic(fmt.Sprintf("%s, %s, %s", instr, instr.X, t))
+			unsupported("index of %s", t)
 		}
 		fmt.Fprintf(ctx.stream, "%s = val;\n", createValueRelName(instr))
 
@@ -853,11 +1274,15 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		fmt.Fprintf(ctx.stream, "uintptr_t index = %s.raw;\n", createValueRelName(instr.Index))
 		switch t := instr.X.Type().Underlying().(type) {
 		case *types.Slice:
+			ctx.emitBoundsCheck("BoundsIndex", createBoundsOperand(instr.Index), fmt.Sprintf("%s.typed.size", createValueRelName(instr.X)))
 			fmt.Fprintf(ctx.stream, "%s* raw = &((%s.typed.ptr)[index]);\n", createTypeName(t.Elem()), createValueRelName(instr.X))
 		case *types.Pointer:
+			ctx.emitNilCheck(instr.X)
+			length := t.Elem().Underlying().(*types.Array).Len()
+			ctx.emitBoundsCheck("BoundsIndex", createBoundsOperand(instr.Index), fmt.Sprintf("%d", length))
 			fmt.Fprintf(ctx.stream, "%s* raw = &(%s.raw->raw[index]);\n", createTypeName(t.Elem().Underlying().(*types.Array).Elem()), createValueRelName(instr.X))
 		default:
-			panic(fmt.Sprintf("%s, %s, %s", instr, instr.X, t))
+			unsupported("address of element of %s", t)
 		}
 		fmt.Fprintf(ctx.stream, "%s = %s;\n", createValueRelName(instr), wrapInObject("raw", instr.Type()))
 
@@ -883,12 +1308,14 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		case *types.Basic:
 			switch xt.Kind() {
 			case types.String, types.UntypedString:
-				raw := fmt.Sprintf("%s.raw[%s.raw]", createValueRelName(instr.X), createValueRelName(instr.Index))
+				ctx.emitBoundsCheck("BoundsIndex", createBoundsOperand(instr.Index), fmt.Sprintf("%s.raw.len", createValueRelName(instr.X)))
+				raw := fmt.Sprintf("%s.raw.ptr[%s.raw]", createValueRelName(instr.X), createValueRelName(instr.Index))
 				fmt.Fprintf(ctx.stream, "%s = %s;\n", createValueRelName(instr), wrapInObject(raw, instr.Type()))
 			default:
-				panic(fmt.Sprintf("%s", instr))
+				unsupported("index of %s", xt)
 			}
 		case *types.Map:
+			ctx.emitHashabilityCheck(instr.Index)
 			result := createValueRelName(instr)
 			key := fmt.Sprintf("&%s", createValueRelName(instr.Index))
 			var value, found string
@@ -906,7 +1333,7 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 				paramArgPair{param: "found", arg: found},
 			)
 		default:
-			panic(fmt.Sprintf("%s", instr))
+			unsupported("index of %s", xt)
 		}
 
 	case *ssa.MakeChan:
@@ -948,6 +1375,7 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		ctx.switchFunctionToCallRuntimeApi("gox5_interface_new", "StackFrameInterfaceNew", createInstructionName(instr), &result, nil,
 			paramArgPair{param: "receiver", arg: fmt.Sprintf("&%s", createValueRelName(instr.X))},
 			paramArgPair{param: "type_id", arg: wrapInTypeId(instr.X.Type())},
+			paramArgPair{param: "itab", arg: createItabReference(instr.Type(), instr.X.Type())},
 		)
 
 	case *ssa.MakeMap:
@@ -968,6 +1396,7 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		)
 
 	case *ssa.MapUpdate:
+		ctx.emitHashabilityCheck(instr.Key)
 		ctx.switchFunctionToCallRuntimeApi("gox5_map_set", "StackFrameMapSet", createInstructionName(instr), nil, nil,
 			paramArgPair{param: "map", arg: fmt.Sprintf("%s.raw", createValueRelName(instr.Map))},
 			paramArgPair{param: "key", arg: fmt.Sprintf("&%s", createValueRelName(instr.Key))},
@@ -1019,10 +1448,15 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 	case *ssa.Phi:
 		basicBlock := instr.Block()
 		for i, edge := range instr.Edges {
+			if edge == instr {
+				// keeps the value, and avoids a self assignment warning
+				fmt.Fprintf(ctx.stream, "\tif (ctx->prev_func.func_ptr == %s) { } else\n", ctx.latestNameMap[basicBlock.Preds[i]])
+				continue
+			}
 			fmt.Fprintf(ctx.stream, "\tif (ctx->prev_func.func_ptr == %s) { %s = %s; } else\n",
 				ctx.latestNameMap[basicBlock.Preds[i]], createValueRelName(instr), createValueRelName(edge))
 		}
-		fmt.Fprintln(ctx.stream, "\t{ assert(false); }")
+		fmt.Fprintln(ctx.stream, "\t{ gox5_abort(\"phi reached from an unknown block\"); }")
 
 	case *ssa.Range:
 		if _, ok := instr.X.Type().(*types.Map); ok {
@@ -1083,24 +1517,24 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		)
 
 	case *ssa.Slice:
-		if t, ok := instr.Type().(*types.Basic); ok {
+		if _, ok := instr.X.Type().Underlying().(*types.Pointer); ok {
+			ctx.emitNilCheck(instr.X)
+		}
+		ctx.emitSliceBoundsChecks(instr)
+		if t, ok := instr.Type().Underlying().(*types.Basic); ok {
 			if t.Kind() != types.String {
-				panic(fmt.Sprintf("%s (%T)", t, t))
+				unsupported("slice of %s", t)
 			}
-			result := createValueRelName(instr)
-			low := "-1"
+			low := "0"
 			if instr.Low != nil {
 				low = fmt.Sprintf("%s.raw", createValueRelName(instr.Low))
 			}
-			high := "-1"
+			high := fmt.Sprintf("%s.raw.len", createValueRelName(instr.X))
 			if instr.High != nil {
 				high = fmt.Sprintf("%s.raw", createValueRelName(instr.High))
 			}
-			ctx.switchFunctionToCallRuntimeApi("gox5_string_substr", "StackFrameStringSubstr", createInstructionName(instr), &result, nil,
-				paramArgPair{param: "base", arg: createValueRelName(instr.X)},
-				paramArgPair{param: "low", arg: low},
-				paramArgPair{param: "high", arg: high},
-			)
+			raw := fmt.Sprintf("gox5_string_slice(%s, %s, %s).raw", createValueRelName(instr.X), low, high)
+			fmt.Fprintf(ctx.stream, "%s = %s;\n", createValueRelName(instr), wrapInObject(raw, instr.Type()))
 		} else {
 			startIndex := "0"
 			if instr.Low != nil {
@@ -1108,31 +1542,33 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 			}
 
 			ptr := ""
-			length := ""
 			switch t := instr.X.Type().Underlying().(type) {
 			case *types.Pointer:
 				ptr = "raw->raw"
-				elemType := t.Elem().Underlying().(*types.Array)
-				length = fmt.Sprintf("%d", elemType.Len())
 			case *types.Slice:
 				ptr = "typed.ptr"
-				length = fmt.Sprintf("%s.typed.capacity", createValueRelName(instr.X))
 			default:
-				panic(fmt.Sprintf("not implemented: %s (%T)", t, t))
+				unsupported("slice of %s", t)
 			}
+			length, capacity := createSliceOperandLengths(instr.X)
 
 			endIndex := length
 			if instr.High != nil {
 				endIndex = fmt.Sprintf("%s.raw", createValueRelName(instr.High))
 			}
+			maxIndex := capacity
+			if instr.Max != nil {
+				maxIndex = fmt.Sprintf("%s.raw", createValueRelName(instr.Max))
+			}
 
 			fmt.Fprintf(ctx.stream, "%s = %s;\n", createValueRelName(instr), wrapInObject("0", instr.Type()))
 			fmt.Fprintf(ctx.stream, "%s.typed.ptr = %s.%s + %s;\n", createValueRelName(instr), createValueRelName(instr.X), ptr, startIndex)
 			fmt.Fprintf(ctx.stream, "%s.typed.size = %s - %s;\n", createValueRelName(instr), endIndex, startIndex)
-			fmt.Fprintf(ctx.stream, "%s.typed.capacity = %s - %s;\n", createValueRelName(instr), length, startIndex)
+			fmt.Fprintf(ctx.stream, "%s.typed.capacity = %s - %s;\n", createValueRelName(instr), maxIndex, startIndex)
 		}
 
 	case *ssa.Store:
+		ctx.emitNilCheck(instr.Addr)
 		fmt.Fprintf(ctx.stream, "*(%s.raw) = %s;\n", createValueRelName(instr.Addr), createValueRelName(instr.Val))
 
 	case *ssa.TypeAssert:
@@ -1145,6 +1581,12 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 			value = fmt.Sprintf("&%s", result)
 			success = "NULL"
 		}
+		pairs := []paramArgPair{
+			{param: "interface", arg: fmt.Sprintf("&%s", createValueRelName(instr.X))},
+			{param: "to_type", arg: wrapInTypeId(instr.AssertedType)},
+			{param: "value", arg: value},
+			{param: "success", arg: success},
+		}
 		var nextFunction, nextFunctionFrame string
 		if _, ok := instr.AssertedType.Underlying().(*types.Interface); ok {
 			nextFunction = "gox5_interface_convert_to_interface"
@@ -1152,13 +1594,10 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		} else {
 			nextFunction = "gox5_interface_convert_to_concrete_type"
 			nextFunctionFrame = "StackFrameInterfaceConvertToConcreteType"
+			// the static type is named in the message of a failed assertion
+			pairs = append(pairs, paramArgPair{param: "from_type", arg: wrapInTypeId(instr.X.Type())})
 		}
-		ctx.switchFunctionToCallRuntimeApi(nextFunction, nextFunctionFrame, createInstructionName(instr), nil, nil,
-			paramArgPair{param: "interface", arg: fmt.Sprintf("&%s", createValueRelName(instr.X))},
-			paramArgPair{param: "to_type", arg: wrapInTypeId(instr.AssertedType)},
-			paramArgPair{param: "value", arg: value},
-			paramArgPair{param: "success", arg: success},
-		)
+		ctx.switchFunctionToCallRuntimeApi(nextFunction, nextFunctionFrame, createInstructionName(instr), nil, nil, pairs...)
 
 	case *ssa.UnOp:
 		if instr.Op == token.ARROW {
@@ -1182,7 +1621,14 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		} else if instr.Op == token.XOR {
 			s := wrapInObject(fmt.Sprintf("~(%s.raw)", createValueRelName(instr.X)), instr.Type())
 			fmt.Fprintf(ctx.stream, "%s = %s;\n", createValueRelName(instr), s)
+		} else if instr.Op == token.SUB && isInteger(instr.Type()) {
+			typ := instr.Type().Underlying().(*types.Basic)
+			s := fmt.Sprintf("(%s)(0 - (%s)(%s.raw))", createRawTypeName(typ), createWrappingRawTypeName(typ), createValueRelName(instr.X))
+			fmt.Fprintf(ctx.stream, "%s = %s;\n", createValueRelName(instr), wrapInObject(s, instr.Type()))
 		} else {
+			if instr.Op == token.MUL {
+				ctx.emitNilCheck(instr.X)
+			}
 			s := fmt.Sprintf("%s (%s.raw)", instr.Op.String(), createValueRelName(instr.X))
 			if instr.Op != token.MUL {
 				s = wrapInObject(s, instr.Type())
@@ -1191,7 +1637,7 @@ func (ctx *Context) emitInstruction(instruction ssa.Instruction) {
 		}
 
 	default:
-		panic(fmt.Sprintf("unknown instruction: %s", instruction.String()))
+		unsupported("instruction %s", instruction)
 	}
 	fmt.Fprintf(ctx.stream, "\t}\n")
 }
@@ -1205,7 +1651,8 @@ func createInstructionName(instruction ssa.Instruction) string {
 				return i
 			}
 		}
-		panic(instruction)
+		unsupported("instruction %s outside of its block", instruction)
+		return -1
 	}()
 	function := instruction.Parent()
 	functionName := function.RelString(nil)
@@ -1223,7 +1670,16 @@ func createFunctionName(function *ssa.Function) string {
 }
 
 func createPackageName(pkg *types.Package) string {
-	return pkg.Name()
+	return pkg.Path()
+}
+
+// changesItab reports whether the conversion asks the runtime for the itab of
+// the interface converted to. Otherwise the itab is kept, as the interfaces
+// have the same methods, or dropped for an empty interface.
+func changesItab(instr *ssa.ChangeInterface) bool {
+	toMethods := instr.Type().Underlying().(*types.Interface).NumMethods()
+	fromMethods := instr.X.Type().Underlying().(*types.Interface).NumMethods()
+	return toMethods != 0 && toMethods != fromMethods
 }
 
 func requireSwitchFunction(instruction ssa.Instruction) bool {
@@ -1239,6 +1695,8 @@ func requireSwitchFunction(instruction ssa.Instruction) bool {
 		return false
 	case *ssa.Call:
 		return true
+	case *ssa.ChangeInterface:
+		return changesItab(t)
 	case *ssa.Convert:
 		if dstType, ok := t.Type().Underlying().(*types.Basic); ok && dstType.Kind() == types.String {
 			return true
@@ -1254,11 +1712,6 @@ func requireSwitchFunction(instruction ssa.Instruction) bool {
 		return ok
 	case *ssa.Next:
 		return true
-	case *ssa.Slice:
-		if dstType, ok := t.Type().(*types.Basic); ok && dstType.Kind() == types.String {
-			return true
-		}
-		return false
 	case *ssa.UnOp:
 		if t.Op == token.ARROW {
 			return true
@@ -1303,6 +1756,7 @@ func (ctx *Context) emitFunctionHeader(name string, end string) {
 }
 
 func (ctx *Context) emitFunctionVariableStructure(function *ssa.Function) {
+	defer ctx.recoverUnsupported(function.String(), function.Pos())
 	signature := function.Signature
 	if signature.Recv() != nil {
 		receiverBoundFuncName := fmt.Sprintf("%s%s", createFunctionName(function), encode("$bound"))
@@ -1346,6 +1800,7 @@ func (ctx *Context) emitFunctionVariableStructure(function *ssa.Function) {
 			case *ssa.Builtin, *ssa.Const, *ssa.Global, *ssa.FreeVar, *ssa.Function, *ssa.Parameter:
 				return
 			}
+			defer ctx.recoverUnsupported(function.String(), value.Pos(), function.Pos())
 
 			if t, ok := value.Type().(*types.Tuple); ok {
 				if t.Len() == 0 {
@@ -1371,10 +1826,11 @@ func (ctx *Context) emitFunctionDefinitionPrologue(functionName string, frameNam
 	if hasFreeVariables {
 		freeVarsCompareOp = "!="
 	}
-	fmt.Fprintf(ctx.stream, `
-	StackFrame_%s* frame = (void*)ctx->stack_pointer;
-	assert(frame->common.free_vars %s NULL);
-`, frameName, freeVarsCompareOp)
+	fmt.Fprintf(ctx.stream, "\n\tStackFrame_%s* frame = (void*)ctx->stack_pointer;\n", frameName)
+	if ctx.stripAssertions {
+		fmt.Fprintf(ctx.stream, "\t(void)frame;\n")
+	}
+	ctx.emitAssertion("frame->common.free_vars %s NULL", freeVarsCompareOp)
 }
 
 func (ctx *Context) emitFunctionDefinitionEpilogue() {
@@ -1382,17 +1838,20 @@ func (ctx *Context) emitFunctionDefinitionEpilogue() {
 }
 
 func (ctx *Context) emitFunctionDefinition(function *ssa.Function) {
+	defer ctx.recoverUnsupported(function.String(), function.Pos())
 	if function.Pkg != nil && function.Pkg.Pkg.Name() == "runtime" && function.Name() == "init" { // ToDo
 		ctx.emitFunctionHeader(createFunctionName(function), "{")
-		fmt.Fprintf(ctx.stream, "\tassert(ctx->marker == 0xdeadbeef);\n")
+		ctx.emitAssertion("ctx->marker == 0xdeadbeef")
 		fmt.Fprintf(ctx.stream, "\tStackFrame_%s* frame = (void*)ctx->stack_pointer;\n", createFunctionName(function))
 		fmt.Fprintf(ctx.stream, "\tctx->stack_pointer = frame->common.prev_stack_pointer;\n")
 		fmt.Fprintf(ctx.stream, "\treturn frame->common.resume_func;\n")
 		fmt.Fprintf(ctx.stream, "}\n")
 		return
 	}
+	ctx.emitGoLine(function.Pos())
 	ctx.emitFunctionHeader(createFunctionName(function), "{")
-	fmt.Fprintf(ctx.stream, "\tassert(ctx->marker == 0xdeadbeef);\n")
+	fmt.Fprintf(ctx.stream, "\t(void)ctx;\n")
+	ctx.emitAssertion("ctx->marker == 0xdeadbeef")
 	fmt.Fprintf(ctx.stream, "\treturn %s;\n", wrapInFunctionObject(createBasicBlockName(function.Blocks[0])))
 	fmt.Fprintf(ctx.stream, "}\n")
 
@@ -1418,12 +1877,13 @@ func (ctx *Context) emitFunctionDefinition(function *ssa.Function) {
 		return
 	}
 
+	ctx.emitGoLine(function.Pos())
 	origFuncName := createFunctionName(function)
 	boundFuncName := fmt.Sprintf("%s%s", origFuncName, encode("$bound"))
 	resumeFuncName := fmt.Sprintf("%s_return", boundFuncName)
 	ctx.emitFunctionDefinitionPrologue(resumeFuncName, boundFuncName, true)
+	ctx.emitAssertion("ctx->marker == 0xdeadbeef")
 	fmt.Fprintf(ctx.stream, `
-	assert(ctx->marker == 0xdeadbeef);
 	ctx->stack_pointer = frame->common.prev_stack_pointer;
 	return frame->common.resume_func;
 `)
@@ -1494,7 +1954,7 @@ func (ctx *Context) retrieveOrderedTypes(pkg *ssa.Package) []types.Type {
 			if typ.String() == "iter" {
 				/// iterator of map or string
 			} else {
-				panic(fmt.Sprintf("not implemented: %s %T", typ, typ))
+				unsupported("type %s", typ)
 			}
 		}
 
@@ -1519,9 +1979,14 @@ func (ctx *Context) emitTypeDeclaration(typ types.Type) {
 	case *types.Array, *types.Chan, *types.Map, *types.Pointer, *types.Struct, *types.Tuple:
 		fmt.Fprintf(ctx.stream, "typedef struct %s %s; // %s\n", name, name, typ)
 
-	case *types.Basic, *types.Interface, *types.Signature:
+	case *types.Basic, *types.Signature:
 		// do nothing
 
+	case *types.Interface:
+		if !typ.Empty() {
+			fmt.Fprintf(ctx.stream, "typedef Interface %s; // %s\n", name, typ)
+		}
+
 	case *types.Named:
 		underlyingTypeName := createTypeName(typ.Underlying())
 		fmt.Fprintf(ctx.stream, "typedef %s %s; // %s\n", underlyingTypeName, name, typ)
@@ -1534,7 +1999,7 @@ func (ctx *Context) emitTypeDeclaration(typ types.Type) {
 			return
 		}
 
-		panic(fmt.Sprintf("not implemented: %s %T", typ, typ))
+		unsupported("type %s", typ)
 	}
 }
 
@@ -1595,7 +2060,7 @@ union %s { // %s
 			return
 		}
 
-		panic(fmt.Sprintf("not implemented: %s %T", typ, typ))
+		unsupported("type %s", typ)
 	}
 }
 
@@ -1641,6 +2106,7 @@ func (ctx *Context) emitSignature(pkg *ssa.Package) {
 	}
 
 	ctx.traverseFunction(pkg, func(function *ssa.Function) {
+		defer ctx.recoverUnsupported(function.String(), function.Pos())
 		signature := function.Signature
 		concreteSignatureName := createSignatureName(signature, false, false)
 		tryEmitSignatureDefinition(signature, concreteSignatureName, false, false)
@@ -1656,10 +2122,54 @@ func (ctx *Context) emitSignature(pkg *ssa.Package) {
 			signature := callCommon.Signature()
 			signatureName := createSignatureName(signature, false, false)
 			tryEmitSignatureDefinition(signature, signatureName, false, false)
+			if callCommon.IsInvoke() {
+				abstractSignatureName := createSignatureName(signature, false, true)
+				tryEmitSignatureDefinition(signature, abstractSignatureName, false, true)
+			}
 		})
 	})
 }
 
+// createGoTypeName returns the name of the type as printed by gc, such as
+// "main.T", "[]int" or "struct { x int }".
+func createGoTypeName(typ types.Type) string {
+	name := types.TypeString(typ, func(pkg *types.Package) string {
+		return pkg.Name()
+	})
+	// go/types leaves out the spaces gc puts inside struct and interface braces
+	var builder strings.Builder
+	spaced := make([]bool, 0)
+	for i := 0; i < len(name); i++ {
+		switch {
+		case strings.HasPrefix(name[i:], "struct{"), strings.HasPrefix(name[i:], "interface{"):
+			keyword := name[i : strings.IndexByte(name[i:], '{')+i]
+			i += len(keyword)
+			if strings.HasPrefix(name[i:], "{}") {
+				builder.WriteString(keyword + " {}")
+				i++
+			} else {
+				builder.WriteString(keyword + " { ")
+				spaced = append(spaced, true)
+			}
+		case name[i] == '{':
+			builder.WriteByte('{')
+			spaced = append(spaced, false)
+		case name[i] == '}':
+			if len(spaced) > 0 && spaced[len(spaced)-1] {
+				builder.WriteString(" }")
+			} else {
+				builder.WriteByte('}')
+			}
+			if len(spaced) > 0 {
+				spaced = spaced[:len(spaced)-1]
+			}
+		default:
+			builder.WriteByte(name[i])
+		}
+	}
+	return builder.String()
+}
+
 func (ctx *Context) emitTypeInfoDeclaration(typ types.Type) {
 	fmt.Fprintf(ctx.stream, "extern const TypeInfo %s;\n", createTypeIdName(typ))
 }
@@ -1670,36 +2180,90 @@ func (ctx *Context) emitTypeInfoDefinition(typ types.Type) {
 	interfaceTable := fmt.Sprintf("&%s.entries[0]", interfaceTableName)
 
 	fmt.Fprintf(ctx.stream, "const TypeInfo %s = {\n", createTypeIdName(typ))
-	fmt.Fprintf(ctx.stream, ".name = \"%s\",\n", createTypeName(typ))
+	fmt.Fprintf(ctx.stream, ".name = {.raw = %s},\n", createStringLiteral(createGoTypeName(typ)))
 	fmt.Fprintf(ctx.stream, ".num_methods = %s,\n", numMethods)
 	fmt.Fprintf(ctx.stream, ".interface_table = %s,\n", interfaceTable)
-	fmt.Fprintf(ctx.stream, ".is_equal = equal_%s,\n", createTypeName(typ))
-	fmt.Fprintf(ctx.stream, ".hash = hash_%s,\n", createTypeName(typ))
+	if types.Comparable(typ) {
+		fmt.Fprintf(ctx.stream, ".is_equal = equal_%s,\n", createTypeName(typ))
+		fmt.Fprintf(ctx.stream, ".hash = hash_%s,\n", createTypeName(typ))
+	} else {
+		fmt.Fprintf(ctx.stream, ".is_equal = NULL,\n")
+		fmt.Fprintf(ctx.stream, ".hash = NULL,\n")
+	}
 	fmt.Fprintf(ctx.stream, ".size = sizeof(%s),\n", createTypeName(typ))
 	fmt.Fprintf(ctx.stream, "};\n")
 }
 
+// createFloatLiteral returns f as a hexadecimal floating constant, which is
+// exact unlike a decimal one, or as a macro of math.h if it is not finite.
+func createFloatLiteral(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NAN"
+	case math.IsInf(f, 1):
+		return "INFINITY"
+	case math.IsInf(f, -1):
+		return "(-INFINITY)"
+	}
+	return strconv.FormatFloat(f, 'x', -1, 64)
+}
+
+// createCStringLiteral returns a C string literal holding s. Only the bytes
+// which C does not accept as they are in a literal are escaped.
+func createCStringLiteral(s string) string {
+	literal := []byte{'"'}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' || c == '"' || (c == '?' && i > 0 && s[i-1] == '?'):
+			// a question mark is escaped after another one, which would start a trigraph
+			literal = append(literal, '\\', c)
+		case c < 0x20 || c == 0x7f:
+			literal = append(literal, fmt.Sprintf("\\%03o", c)...)
+		default:
+			literal = append(literal, c)
+		}
+	}
+	return string(append(literal, '"'))
+}
+
+// createStringLiteral returns the initializer of the raw value of a string
+// holding s, whose bytes are all escaped so that any of them may appear.
+func createStringLiteral(s string) string {
+	literal := "\""
+	for i := 0; i < len(s); i++ {
+		literal += fmt.Sprintf("\\x%02x", s[i])
+	}
+	literal += "\""
+	return fmt.Sprintf("{(const uint8_t *)%s, %d}", literal, len(s))
+}
+
 func (ctx *Context) emitConstant(cst *ssa.Const) {
 	inner := "0"
 	if !cst.IsNil() {
 		inner = cst.Value.String()
 		if t, ok := cst.Type().Underlying().(*types.Basic); ok {
 			switch t.Kind() {
-			case types.Complex64, types.Complex128:
-				inner = fmt.Sprintf("%g", cst.Complex128())
+			case types.Complex64:
+				c := cst.Complex128()
+				inner = fmt.Sprintf("CMPLXF(%s, %s)", createFloatLiteral(real(c)), createFloatLiteral(imag(c)))
+			case types.Complex128:
+				c := cst.Complex128()
+				inner = fmt.Sprintf("CMPLX(%s, %s)", createFloatLiteral(real(c)), createFloatLiteral(imag(c)))
 			case types.Float32, types.Float64:
-				inner = fmt.Sprintf("%g", cst.Float64())
-			case types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64, types.Uintptr:
+				inner = createFloatLiteral(cst.Float64())
+			case types.Uint, types.Uint64, types.Uintptr:
+				inner = fmt.Sprintf("UINT64_C(%s)", inner)
+			case types.Uint8, types.Uint16, types.Uint32:
 				inner = fmt.Sprintf("%su", inner)
 			case types.Int, types.Int64:
-				inner = fmt.Sprintf("%slu", inner)
-			case types.String, types.UntypedString:
-				inner = "\""
-				fullString := constant.StringVal(cst.Value)
-				for i := 0; i < len(fullString); i++ {
-					inner += fmt.Sprintf("\\x%02x", fullString[i])
+				if cst.Int64() == math.MinInt64 {
+					// the negation of 9223372036854775808, which has no signed type
+					inner = "INT64_MIN"
+				} else {
+					inner = fmt.Sprintf("INT64_C(%s)", inner)
 				}
-				inner += "\""
+			case types.String, types.UntypedString:
+				inner = createStringLiteral(constant.StringVal(cst.Value))
 			case types.UnsafePointer:
 				inner = fmt.Sprintf("(void*)%su", inner)
 			}
@@ -1727,8 +2291,10 @@ func (ctx *Context) emitEqualFunctionDefinition(typ types.Type) {
 	typeName := createTypeName(typ)
 	underlyingType := typ.Underlying()
 	var body = ""
-	body += "\tassert(lhs != NULL);\n"
-	body += "\tassert(rhs != NULL);\n"
+	body += "\t(void)lhs;\n"
+	body += "\t(void)rhs;\n"
+	body += ctx.assertion("lhs != NULL")
+	body += ctx.assertion("rhs != NULL")
 	if typ == underlyingType {
 		switch t := typ.(type) {
 		case *types.Basic:
@@ -1736,14 +2302,25 @@ func (ctx *Context) emitEqualFunctionDefinition(typ types.Type) {
 			case types.Invalid:
 				body += "return true;\n"
 			case types.String:
-				body += "return strcmp(lhs->raw, rhs->raw) == 0;\n"
+				body += "return gox5_string_compare(*lhs, *rhs) == 0;\n"
 			default:
 				body += "return lhs->raw == rhs->raw;\n"
 			}
+		case *types.Array:
+			if t.Len() > 0 {
+				body += fmt.Sprintf("for (uintptr_t i = 0; i < %d; ++i) {\n", t.Len())
+				body += fmt.Sprintf("if (!equal_%s(&lhs->raw[i], &rhs->raw[i])) { return false; }\n", createTypeName(t.Elem()))
+				body += "}\n"
+			}
+			body += "return true;\n"
+		case *types.Chan:
+			body += "return lhs->raw.raw == rhs->raw.raw;\n"
 		case *types.Interface:
-			panic(typ)
+			body += "return equal_Interface(lhs, rhs);\n"
 		case *types.Map:
 			body += "return equal_MapObject(&lhs->raw, &rhs->raw);\n"
+		case *types.Pointer:
+			body += "return lhs->raw == rhs->raw;\n"
 		case *types.Struct:
 			for i := 0; i < t.NumFields(); i++ {
 				field := t.Field(i)
@@ -1774,22 +2351,37 @@ func (ctx *Context) emitHashFunctionDefinition(typ types.Type) {
 	typeName := createTypeName(typ)
 	underlyingType := typ.Underlying()
 	var body = ""
-	body += "\tassert(obj != NULL);\n"
+	body += "\t(void)obj;\n"
+	body += ctx.assertion("obj != NULL")
 	if typ == underlyingType {
 		switch t := typ.(type) {
 		case *types.Basic:
 			switch t.Kind() {
-			case types.Invalid, types.String:
-				body += "assert(false); /// not implemented\n"
+			case types.Invalid:
 				body += "return 0;\n"
+			case types.String:
+				body += "return gox5_hash_bytes(obj->raw.ptr, obj->raw.len);\n"
+			case types.Float32, types.Float64:
+				body += "return gox5_hash_float(obj->raw);\n"
+			case types.Complex64, types.Complex128:
+				body += "return gox5_hash_combine(gox5_hash_float(creal(obj->raw)), gox5_hash_float(cimag(obj->raw)));\n"
 			default:
 				body += "return (uintptr_t)obj->raw;\n"
 			}
+		case *types.Array:
+			body += "uintptr_t hash = 0;\n"
+			if t.Len() > 0 {
+				body += fmt.Sprintf("for (uintptr_t i = 0; i < %d; ++i) {\n", t.Len())
+				body += fmt.Sprintf("hash = gox5_hash_combine(hash, hash_%s(&obj->raw[i]));\n", createTypeName(t.Elem()))
+				body += "}\n"
+			}
+			body += "return hash;\n"
+		case *types.Chan:
+			body += "return (uintptr_t)obj->raw.raw;\n"
 		case *types.Interface:
-			panic(typ)
-		case *types.Map:
-			body += "assert(false); /// not implemented\n"
-			body += "return 0;\n"
+			body += "return hash_Interface(obj);\n"
+		case *types.Pointer:
+			body += "return (uintptr_t)obj->raw;\n"
 		case *types.Struct:
 			body += "uintptr_t hash = 0;\n"
 			for i := 0; i < t.NumFields(); i++ {
@@ -1798,12 +2390,12 @@ func (ctx *Context) emitHashFunctionDefinition(typ types.Type) {
 					continue
 				}
 				name := createFieldName(field, i)
-				body += fmt.Sprintf("hash += hash_%s(&obj->%s); // %s\n", createTypeName(field.Type()), name, field)
+				body += fmt.Sprintf("hash = gox5_hash_combine(hash, hash_%s(&obj->%s)); // %s\n", createTypeName(field.Type()), name, field)
 			}
 			body += "return hash;\n"
 		default:
-			body += "assert(false); /// not implemented\n"
-			body += "return 0;\n"
+			// maps, slices and functions are not comparable
+			body += "gox5_abort(\"hash of an uncomparable type\");\n"
 		}
 	} else {
 		body += fmt.Sprintf("return hash_%s(obj);\n", createTypeName(underlyingType))
@@ -1813,44 +2405,150 @@ func (ctx *Context) emitHashFunctionDefinition(typ types.Type) {
 	fmt.Fprintf(ctx.stream, "}\n")
 }
 
-func (ctx *Context) emitInterfaceTableDeclaration(typ types.Type, allowSet map[string]struct{}) {
+// interfaceTableEntry is a method listed in an interface table. Its function
+// is nil in the table of an interface type.
+type interfaceTableEntry struct {
+	method   *types.Func
+	function *ssa.Function
+}
+
+// methodPackagePath returns the path of the package qualifying the name of the
+// method, which is empty for exported names.
+func methodPackagePath(method *types.Func) string {
+	if method.Exported() || method.Pkg() == nil {
+		return ""
+	}
+	return method.Pkg().Path()
+}
+
+// createMethodSignature returns the string by which the runtime identifies the
+// signature of the method, the same for all identical signatures.
+func (ctx *Context) createMethodSignature(method *types.Func) string {
+	signature := method.Type().(*types.Signature)
+	unnamed := func(tuple *types.Tuple) *types.Tuple {
+		vars := make([]*types.Var, tuple.Len())
+		for i := range vars {
+			vars[i] = types.NewParam(token.NoPos, nil, "", tuple.At(i).Type())
+		}
+		return types.NewTuple(vars...)
+	}
+	signature = types.NewSignature(nil, unnamed(signature.Params()), unnamed(signature.Results()), signature.Variadic())
+	// names of nested parameters are printed as well, so identical signatures
+	// share the string of the first one met
+	if s := ctx.methodSignatures.At(signature); s != nil {
+		return s.(string)
+	}
+	s := types.TypeString(signature, func(pkg *types.Package) string {
+		return pkg.Path()
+	})
+	ctx.methodSignatures.Set(signature, s)
+	return s
+}
+
+// interfaceTableEntries returns the methods listed in the interface table of
+// the type. Unreachable methods are left out, as a value of the type is then
+// never stored in an interface. The table of an interface type lists the
+// methods it requires, for the runtime to check conversions to it.
+func (ctx *Context) interfaceTableEntries(typ types.Type, allowSet map[string]struct{}) []interfaceTableEntry {
+	entries := make([]interfaceTableEntry, 0)
+	if iface, ok := typ.Underlying().(*types.Interface); ok {
+		for i := 0; i < iface.NumMethods(); i++ {
+			entries = append(entries, interfaceTableEntry{method: iface.Method(i)})
+		}
+		return entries
+	}
+	if _, ok := allowSet[createTypeName(typ)]; !ok {
+		return entries
+	}
 	methodSet := ctx.program.MethodSets.MethodSet(typ)
-	entryIndexes := make([]int, 0)
-	if _, ok := allowSet[createTypeName(typ)]; ok {
-		for i := 0; i < methodSet.Len(); i++ {
-			function := ctx.program.MethodValue(methodSet.At(i))
-			if function != nil {
-				entryIndexes = append(entryIndexes, i)
-			}
+	for i := 0; i < methodSet.Len(); i++ {
+		function := ctx.program.MethodValue(methodSet.At(i))
+		if function != nil && ctx.isReachable(function) {
+			entries = append(entries, interfaceTableEntry{method: methodSet.At(i).Obj().(*types.Func), function: function})
 		}
 	}
+	return entries
+}
+
+func (ctx *Context) emitInterfaceTableDeclaration(typ types.Type, allowSet map[string]struct{}) {
+	entries := ctx.interfaceTableEntries(typ, allowSet)
 	name := createTypeName(typ)
-	fmt.Fprintf(ctx.stream, "struct InterfaceTable_%s { InterfaceTableEntry entries[%d]; };\n", name, len(entryIndexes))
+	fmt.Fprintf(ctx.stream, "struct InterfaceTable_%s { InterfaceTableEntry entries[%d]; };\n", name, len(entries))
 	fmt.Fprintf(ctx.stream, "extern struct InterfaceTable_%s interfaceTable_%s;\n", name, name)
 }
 
 func (ctx *Context) emitInterfaceTableDefinition(typ types.Type, allowSet map[string]struct{}) {
-	methodSet := ctx.program.MethodSets.MethodSet(typ)
-	entryIndexes := make([]int, 0)
-	if _, ok := allowSet[createTypeName(typ)]; ok {
-		for i := 0; i < methodSet.Len(); i++ {
-			function := ctx.program.MethodValue(methodSet.At(i))
-			if function != nil {
-				entryIndexes = append(entryIndexes, i)
-			}
-		}
-	}
+	entries := ctx.interfaceTableEntries(typ, allowSet)
 	name := createTypeName(typ)
 	fmt.Fprintf(ctx.stream, "struct InterfaceTable_%s interfaceTable_%s = {{\n", name, name)
-	for _, index := range entryIndexes {
-		function := ctx.program.MethodValue(methodSet.At(index))
-		methodName := function.Name()
-		method := wrapInFunctionObject(createFunctionName(function))
-		fmt.Fprintf(ctx.stream, "\t{\"%s\", %s},\n", methodName, method)
+	for _, entry := range entries {
+		method := wrapInFunctionObject("NULL")
+		if entry.function != nil {
+			method = wrapInFunctionObject(createFunctionName(entry.function))
+		}
+		fmt.Fprintf(ctx.stream, "\t{{.raw = %s}, {.raw = %s}, {.raw = %s}, %s},\n",
+			createStringLiteral(entry.method.Name()),
+			createStringLiteral(methodPackagePath(entry.method)),
+			createStringLiteral(ctx.createMethodSignature(entry.method)),
+			method)
 	}
 	fmt.Fprintln(ctx.stream, "}};")
 }
 
+func createItabName(iface types.Type, typ types.Type) string {
+	return fmt.Sprintf("itab_%s%s%s", createTypeName(iface), encode("$"), createTypeName(typ))
+}
+
+// createItabReference returns the itab of the interface type for values of
+// the concrete type, which is NULL for empty interfaces.
+func createItabReference(iface types.Type, typ types.Type) string {
+	if iface.Underlying().(*types.Interface).Empty() {
+		return "NULL"
+	}
+	return createItabName(iface, typ)
+}
+
+// traverseItab calls procedure for each pair of a non-empty interface type and
+// a concrete type converted to it in the package, or in all packages if nil.
+func (ctx *Context) traverseItab(pkg *ssa.Package, procedure func(iface types.Type, typ types.Type)) {
+	foundItabSet := make(map[string]struct{})
+	ctx.traverseFunction(pkg, func(function *ssa.Function) {
+		ctx.traverseValue(function, func(value ssa.Value) {
+			instr, ok := value.(*ssa.MakeInterface)
+			if !ok || instr.Type().Underlying().(*types.Interface).Empty() {
+				return
+			}
+			name := createItabName(instr.Type(), instr.X.Type())
+			if _, ok := foundItabSet[name]; ok {
+				return
+			}
+			foundItabSet[name] = struct{}{}
+			procedure(instr.Type(), instr.X.Type())
+		})
+	})
+}
+
+func (ctx *Context) emitItabDeclaration(iface types.Type, typ types.Type) {
+	numMethods := iface.Underlying().(*types.Interface).NumMethods()
+	fmt.Fprintf(ctx.stream, "extern const FunctionObject %s[%d];\n", createItabName(iface, typ), numMethods)
+}
+
+func (ctx *Context) emitItabDefinition(iface types.Type, typ types.Type, allowSet map[string]struct{}) {
+	entries := ctx.interfaceTableEntries(typ, allowSet)
+	methods := iface.Underlying().(*types.Interface)
+	fmt.Fprintf(ctx.stream, "const FunctionObject %s[%d] = {\n", createItabName(iface, typ), methods.NumMethods())
+	for i := 0; i < methods.NumMethods(); i++ {
+		method := wrapInFunctionObject("gox5_unreachable_method")
+		for _, entry := range entries {
+			if entry.method.Id() == methods.Method(i).Id() {
+				method = wrapInFunctionObject(createFunctionName(entry.function))
+			}
+		}
+		fmt.Fprintf(ctx.stream, "\t%s, // %s\n", method, methods.Method(i).Name())
+	}
+	fmt.Fprintln(ctx.stream, "};")
+}
+
 func (ctx *Context) emitGlobalVariableDeclaration(gv *ssa.Global) {
 	name := createValueName(gv)
 	fmt.Fprintf(ctx.stream, "extern %s %s;\n", createTypeName(gv.Type().(*types.Pointer).Elem()), name)
@@ -1862,7 +2560,7 @@ func (ctx *Context) emitGlobalVariableDefinition(gv *ssa.Global) {
 }
 
 func (ctx *Context) emitRuntimeInfo() {
-	mainPkg := findMainPackage(ctx.program)
+	mainPkg := ctx.mainPackage
 	mainFunctionName := createFunctionName(mainPkg.Members["main"].(*ssa.Function))
 	initFunctionName := createFunctionName(mainPkg.Members["init"].(*ssa.Function))
 
@@ -1879,15 +2577,6 @@ UserFunction runtime_info_get_init_point(void) {
 `, mainFunctionName, initFunctionName)
 }
 
-func findMainPackage(program *ssa.Program) *ssa.Package {
-	for _, pkg := range program.AllPackages() {
-		if pkg.Pkg.Name() == "main" {
-			return pkg
-		}
-	}
-	panic("main package not found")
-}
-
 func (ctx *Context) traverseValue(function *ssa.Function, procedure func(value ssa.Value)) {
 	foundValueSet := make(map[ssa.Value]struct{})
 	var f func(value ssa.Value)
@@ -2004,8 +2693,7 @@ func (ctx *Context) traverseValue(function *ssa.Function, procedure func(value s
 			f(val.X)
 
 		default:
-			value.Parent().WriteTo(os.Stderr)
-			panic(fmt.Sprintf("unknown value: %s : %T", value.String(), value))
+			unsupported("value %s", value)
 		}
 
 		procedure(value)
@@ -2051,8 +2739,7 @@ func (ctx *Context) traverseValue(function *ssa.Function, procedure func(value s
 				f(instr.Val)
 
 			default:
-				instr.Parent().WriteTo(os.Stderr)
-				panic(fmt.Sprintf("unknown value: %s : %T", instr.String(), instr))
+				unsupported("instruction %s", instr)
 			}
 		}
 	}
@@ -2076,7 +2763,9 @@ func (ctx *Context) traverseCallCommon(function *ssa.Function, procedure func(ca
 func (ctx *Context) traverseFunction(pkg *ssa.Package, procedure func(function *ssa.Function)) {
 	var f func(function *ssa.Function)
 	f = func(function *ssa.Function) {
-		procedure(function)
+		if ctx.isReachable(function) {
+			procedure(function)
+		}
 		for _, anonFunc := range function.AnonFuncs {
 			f(anonFunc)
 		}
@@ -2168,7 +2857,7 @@ func (ctx *Context) traverseType(pkg *ssa.Package, procedure func(typ types.Type
 				/// iterator of map or string
 				return
 			}
-			panic(fmt.Sprintf("not implemented: %s %T", typ, typ))
+			unsupported("type %s", typ)
 		}
 
 		procedure(typ)
@@ -2179,7 +2868,9 @@ func (ctx *Context) traverseType(pkg *ssa.Package, procedure func(typ types.Type
 		case *ssa.Global:
 			f(member.Type())
 		case *ssa.Type:
-			f(member.Type())
+			if ctx.reachable == nil {
+				f(member.Type())
+			}
 		}
 	})
 
@@ -2271,13 +2962,15 @@ uintptr_t hash_Interface(const Interface* obj);
 		ctx.emitTypeInfoDeclaration(typ)
 	})
 	ctx.traverseType(pkg, func(typ types.Type) {
-		if _, ok := typ.(*types.Interface); !ok {
+		defer ctx.recoverUnsupported(typ.String(), typePos(typ))
+		if t, ok := typ.(*types.Interface); !ok || !t.Empty() {
 			ctx.emitEqualFunctionDeclaration(typ)
 			ctx.emitHashFunctionDeclaration(typ)
 		}
 		ctx.emitInterfaceTableDeclaration(typ, allowSet)
 		ctx.emitTypeInfoDeclaration(typ)
 	})
+	ctx.traverseItab(pkg, ctx.emitItabDeclaration)
 }
 
 func (ctx *Context) emitInterfaceDataDefinition() {
@@ -2302,38 +2995,48 @@ bool equal_MapObject(const MapObject* lhs, const MapObject* rhs) {
 	if((lhs->raw == NULL) || (rhs->raw == NULL)) {
 		return false;
 	}
-	assert(false); // ToDo: unimplemented
-	return false;
+	gox5_abort("comparison of non-nil maps");
 }
 
+const TypeInfo *gox5_uncomparable_type = NULL;
+
 bool equal_Interface(const Interface* lhs, const Interface* rhs) {
 	assert(lhs!=NULL);
 	assert(rhs!=NULL);
 
-	if (lhs->type_id.info == NULL && rhs->type_id.info == NULL) {
-		return true;
-	}
-
-	if ((lhs->type_id.info == NULL) || (rhs->type_id.info == NULL)) {
+	if (lhs->type_id.id != rhs->type_id.id) {
 		return false;
 	}
 
-	if ((lhs->receiver == NULL) && (rhs->receiver == NULL)) {
+	if (lhs->type_id.info == NULL) {
 		return true;
 	}
 
 	if ((lhs->receiver == NULL) || (rhs->receiver == NULL)) {
-		return false;
+		return lhs->receiver == rhs->receiver;
 	}
 
 	bool (*f)(const void*, const void*) = lhs->type_id.info->is_equal;
+	if (f == NULL) {
+		gox5_uncomparable_type = lhs->type_id.info;
+		return false;
+	}
 	return f(lhs->receiver, rhs->receiver);
 }
 
 uintptr_t hash_Interface(const Interface* obj) {
-	(void)obj;
-	assert(false); /// not implemented
-	return 0;
+	assert(obj!=NULL);
+
+	if ((obj->type_id.info == NULL) || (obj->receiver == NULL)) {
+		return 0;
+	}
+
+	uintptr_t (*f)(const void*) = obj->type_id.info->hash;
+	if (f == NULL) {
+		gox5_uncomparable_type = obj->type_id.info;
+		return 0;
+	}
+	return gox5_hash_combine(obj->type_id.id, f(obj->receiver));
 }
 `)
 
@@ -2344,16 +3047,21 @@ uintptr_t hash_Interface(const Interface* obj) {
 		ctx.emitTypeInfoDefinition(typ)
 	})
 	ctx.traverseType(nil, func(typ types.Type) {
-		if _, ok := typ.(*types.Interface); !ok {
+		defer ctx.recoverUnsupported(typ.String(), typePos(typ))
+		if t, ok := typ.(*types.Interface); !ok || !t.Empty() {
 			ctx.emitEqualFunctionDefinition(typ)
 			ctx.emitHashFunctionDefinition(typ)
 		}
 		ctx.emitInterfaceTableDefinition(typ, allowSet)
 		ctx.emitTypeInfoDefinition(typ)
 	})
+	ctx.traverseItab(nil, func(iface types.Type, typ types.Type) {
+		ctx.emitItabDefinition(iface, typ, allowSet)
+	})
 }
 
 func (ctx *Context) emitPackage(pkg *ssa.Package) {
+	defer ctx.recoverUnsupported(pkg.Pkg.Path())
 	ctx.emitCommon()
 
 	ctx.emitTypeDeclarationAndDefinition(pkg)
@@ -2403,74 +3111,101 @@ func (ctx *Context) emitPackage(pkg *ssa.Package) {
 				ctx.emitConstant(cst)
 			}
 		})
-		for _, basicBlock := range function.Blocks {
-			name := createBasicBlockName(basicBlock)
-			ctx.emitFunctionHeader(name, ";")
-			ctx.latestNameMap[basicBlock] = name
-			for _, instr := range basicBlock.Instrs {
-				if requireSwitchFunction(instr) {
-					continuationName := createInstructionName(instr)
-					ctx.emitFunctionHeader(continuationName, ";")
-					ctx.latestNameMap[basicBlock] = continuationName
-				}
-			}
-		}
+		ctx.emitContinuationDeclarations(function)
 		ctx.emitFunctionDefinition(function)
+		ctx.emitCLine()
 	})
 }
 
-func generateMakefile(makefile *os.File, program *ssa.Program) {
-	cCompiler := "gcc"
-	cCompilerWrapper := "ccache"
-	cc := fmt.Sprintf("$(shell command -v %s >/dev/null 2>&1 && echo %s %s || echo %s)", cCompilerWrapper, cCompilerWrapper, cCompiler, cCompiler)
+// emitContinuationDeclarations declares the C functions into which the basic
+// blocks of the function are split, and records the last one of each block.
+func (ctx *Context) emitContinuationDeclarations(function *ssa.Function) {
+	for _, basicBlock := range function.Blocks {
+		name := createBasicBlockName(basicBlock)
+		ctx.emitFunctionHeader(name, ";")
+		ctx.latestNameMap[basicBlock] = name
+		for _, instr := range basicBlock.Instrs {
+			if requireSwitchFunction(instr) {
+				continuationName := createInstructionName(instr)
+				ctx.emitFunctionHeader(continuationName, ";")
+				ctx.latestNameMap[basicBlock] = continuationName
+			}
+		}
+	}
+}
+
+const binaryName = "bin.exe"
+
+func generateMakefile(makefile io.Writer, program *ssa.Program, runtimeArchive string, opts *buildOptions) {
+	profile := opts.profile
+	cc := escapeMakeVariable(opts.toolchain.cc)
+	if cc == "" {
+		cCompiler := "gcc"
+		cCompilerWrapper := "ccache"
+		cc = fmt.Sprintf("$(shell command -v %s >/dev/null 2>&1 && echo %s %s || echo %s)", cCompilerWrapper, cCompilerWrapper, cCompiler, cCompiler)
+	}
 	cflags := []string{
-		"-Wall", "-Wextra", "-Werror", "-std=c11", "-g",
+		"-Wall", "-Wextra", "-Werror", "-std=c11",
 		"-fstrict-aliasing", "-Wstrict-aliasing",
-		"-fsanitize=undefined", "-fno-sanitize-recover=all",
 	}
-	ldflags := []string{
-		"-fsanitize=undefined", "-fno-sanitize-recover=all",
-		"-lpthread", "-ldl", "-lm",
+	ldflags := []string{}
+	if profile.isRelease() {
+		cflags = append(cflags, "-O2", "-DNDEBUG")
+		if profile.lto {
+			cflags = append(cflags, "-flto")
+			ldflags = append(ldflags, "-O2", "-flto")
+		}
+	} else {
+		sanitizerFlags := []string{"-fsanitize=undefined", "-fno-sanitize-recover=all"}
+		cflags = append(cflags, "-g")
+		cflags = append(cflags, sanitizerFlags...)
+		ldflags = append(ldflags, sanitizerFlags...)
+	}
+	ldflags = append(ldflags, "-lpthread", "-ldl", "-lm")
+	if extra := escapeMakeVariable(opts.toolchain.cflags); extra != "" {
+		cflags = append(cflags, extra)
+	}
+	if extra := escapeMakeVariable(opts.toolchain.ldflags); extra != "" {
+		ldflags = append(ldflags, extra)
 	}
-	libs := []string{"../target/debug/libgogogogogo.a"}
+	libs := []string{runtimeArchive}
 	fmt.Fprintf(makefile, "CC = %s\n", cc)
 	fmt.Fprintf(makefile, "CFLAGS = %s\n", strings.Join(cflags, " "))
 	fmt.Fprintf(makefile, "LDFLAGS = %s\n", strings.Join(ldflags, " "))
 	fmt.Fprintf(makefile, "LIBS = %s\n", strings.Join(libs, " "))
 
-	binaryName := "bin.exe"
 	fmt.Fprintf(makefile, "all: %s\n", binaryName)
 
 	objs := []string{}
 	cFileRule := func(outputName string) {
 		objName := fmt.Sprintf("%s.o", outputName)
-		fmt.Fprintf(makefile, "%s:\n", objName)
+		fmt.Fprintf(makefile, "%s: %s predefined.h Makefile\n", objName, outputName)
 		fmt.Fprintf(makefile, "\t@$(CC) $(CFLAGS) -c -o %s %s\n", objName, outputName)
 		objs = append(objs, objName)
 	}
 
 	cFileRule("shared_definition.c")
 	for _, pkg := range program.AllPackages() {
-		outputName := fmt.Sprintf("package_%s.c", createPackageName(pkg.Pkg))
+		outputName := fmt.Sprintf("package_%s.c", encode(createPackageName(pkg.Pkg)))
 		cFileRule(outputName)
 	}
 
-	fmt.Fprintf(makefile, "%s: %s\n", binaryName, strings.Join(objs, " "))
+	fmt.Fprintf(makefile, "%s: %s $(LIBS)\n", binaryName, strings.Join(objs, " "))
 	fmt.Fprintf(makefile, "\t@$(CC) -o %s %s $(LIBS) $(LDFLAGS)\n", binaryName, strings.Join(objs, " "))
 }
 
-func handleSharedDefinition(program *ssa.Program, outputPath string) {
-	f, err := os.Create(outputPath)
-	if err != nil {
-		panic(err)
-	}
-	defer f.Close()
-
+func handleSharedDefinition(program *ssa.Program, mainPkg *ssa.Package, outputPath string, reachable map[*ssa.Function]struct{}, opts *buildOptions, diagnostics *diagnostics) error {
+	var buf bytes.Buffer
 	ctx := Context{
-		stream:        f,
-		program:       program,
-		latestNameMap: make(map[*ssa.BasicBlock]string),
+		stream:          &buf,
+		program:         program,
+		mainPackage:     mainPkg,
+		latestNameMap:   make(map[*ssa.BasicBlock]string),
+		diagnostics:     diagnostics,
+		reachable:       reachable,
+		stripAssertions: opts.profile.isRelease(),
 	}
+	defer ctx.recoverUnsupported("shared definition")
 
 	ctx.emitCommon()
 
@@ -2483,68 +3218,134 @@ func handleSharedDefinition(program *ssa.Program, outputPath string) {
 	ctx.emitInterfaceDataDefinition()
 
 	ctx.traverseFunction(nil, func(function *ssa.Function) {
-		if function.Blocks != nil {
+		if !isStubbedFunction(function) {
 			return
 		}
-		if createFunctionName(function) == "f_24_runtime_2E_mcall" {
-			return
-		}
-		fmt.Fprintf(ctx.stream, "FunctionObject %s(LightWeightThreadContext* ctx){ (void)ctx; assert(false); return (FunctionObject){NULL}; }", createFunctionName(function))
+		message := createCStringLiteral(fmt.Sprintf("not implemented: %s", function))
+		fmt.Fprintf(ctx.stream, "FunctionObject %s(LightWeightThreadContext* ctx){ (void)ctx; gox5_abort(%s); }\n", createFunctionName(function), message)
 	})
 
 	ctx.emitRuntimeInfo()
+
+	return writeFileIfChanged(outputPath, buf.Bytes())
 }
 
-func handlePackage(program *ssa.Program, pkg *ssa.Package, outputPath string) {
-	f, err := os.Create(outputPath)
-	if err != nil {
-		panic(err)
-	}
-	defer f.Close()
+// isStubbedFunction reports whether the function has no Go body, so that a
+// stub failing at run time is emitted for it in the shared definition.
+func isStubbedFunction(function *ssa.Function) bool {
+	return function.Blocks == nil && createFunctionName(function) != "f_24_runtime_2E_mcall"
+}
 
+func handlePackage(program *ssa.Program, pkg *ssa.Package, outputPath string, reachable map[*ssa.Function]struct{}, opts *buildOptions, diagnostics *diagnostics) error {
+	var buf bytes.Buffer
 	ctx := Context{
-		stream:        f,
-		program:       program,
-		latestNameMap: make(map[*ssa.BasicBlock]string),
+		stream:          &buf,
+		program:         program,
+		latestNameMap:   make(map[*ssa.BasicBlock]string),
+		diagnostics:     diagnostics,
+		reachable:       reachable,
+		stripAssertions: opts.profile.isRelease(),
+	}
+	if opts.lineDirectives {
+		ctx.lineWriter = newLineWriter(&buf, filepath.Base(outputPath))
+		ctx.stream = ctx.lineWriter
 	}
 
 	ctx.emitPackage(pkg)
+
+	return writeFileIfChanged(outputPath, buf.Bytes())
 }
 
-func handleMakefile(program *ssa.Program, outputPath string) {
-	makefile, err := os.Create(outputPath)
-	if err != nil {
-		panic(err)
+// emittedFunctionNames returns the Go names of the functions emitted for pkg,
+// which identify the reachable part of the package. Unlike the C names, they
+// are known for every function, even one cgen cannot translate.
+func emittedFunctionNames(program *ssa.Program, pkg *ssa.Package, reachable map[*ssa.Function]struct{}) []string {
+	ctx := Context{
+		program:   program,
+		reachable: reachable,
 	}
-	defer makefile.Close()
-	generateMakefile(makefile, program)
+	names := []string{}
+	ctx.traverseFunction(pkg, func(function *ssa.Function) {
+		names = append(names, function.String())
+	})
+	return names
 }
 
-func emitProgram(program *ssa.Program, buildDirname string) {
-	waitGroup := sync.WaitGroup{}
+// escapeMakeVariable keeps make from expanding the user given value, which is
+// otherwise passed to the shell as is.
+func escapeMakeVariable(value string) string {
+	return strings.ReplaceAll(strings.TrimSpace(value), "$", "$$")
+}
 
-	waitGroup.Add(1)
-	go func() {
-		definitionName := "shared_definition.c"
-		handleSharedDefinition(program, fmt.Sprintf("%s/%s", buildDirname, definitionName))
-		waitGroup.Done()
-	}()
+func handleMakefile(program *ssa.Program, outputPath string, runtimeArchive string, opts *buildOptions) error {
+	var makefile bytes.Buffer
+	generateMakefile(&makefile, program, runtimeArchive, opts)
+	return writeFileIfChanged(outputPath, makefile.Bytes())
+}
 
-	for _, pkg := range program.AllPackages() {
+// emitProgram writes the C code of the program and its Makefile into
+// buildDirname, to be compiled as configured by opts. Only the functions in
+// reachable are emitted, unless it is nil. The files found in cache, unless it
+// is nil, are restored instead of being emitted. Unsupported constructs found
+// in any package are reported to stderr, and an error is returned when there
+// is at least one of them.
+func emitProgram(program *ssa.Program, mainPkg *ssa.Package, reachable map[*ssa.Function]struct{}, opts *buildOptions, cache *buildCache, buildDirname string, runtimeArchive string) error {
+	// the Makefile is written first, as restored object files must be newer
+	if err := handleMakefile(program, fmt.Sprintf("%s/%s", buildDirname, "Makefile"), runtimeArchive, opts); err != nil {
+		return err
+	}
+
+	diagnostics := newDiagnostics(program.Fset)
+	waitGroup := sync.WaitGroup{}
+	errMutex := sync.Mutex{}
+	var firstErr error
+	emit := func(key string, outputName string, handler func(outputPath string) error) {
 		waitGroup.Add(1)
-		go func(pkg *ssa.Package) {
-			outputName := fmt.Sprintf("package_%s.c", createPackageName(pkg.Pkg))
-			handlePackage(program, pkg, fmt.Sprintf("%s/%s", buildDirname, outputName))
-			waitGroup.Done()
-		}(pkg)
-	}
-
-	waitGroup.Add(1)
-	go func() {
-		makefileName := "Makefile"
-		handleMakefile(program, fmt.Sprintf("%s/%s", buildDirname, makefileName))
-		waitGroup.Done()
-	}()
+		go func() {
+			defer waitGroup.Done()
+			if cache != nil && cache.restore(key, buildDirname, outputName) {
+				return
+			}
+			if err := handler(fmt.Sprintf("%s/%s", buildDirname, outputName)); err != nil {
+				errMutex.Lock()
+				defer errMutex.Unlock()
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}()
+	}
+
+	packageKeys := []string{}
+	for _, pkg := range program.AllPackages() {
+		pkg := pkg
+		outputName := fmt.Sprintf("package_%s.c", encode(createPackageName(pkg.Pkg)))
+		key := ""
+		if cache != nil {
+			key = cache.packageKey(pkg.Pkg, emittedFunctionNames(program, pkg, reachable))
+			packageKeys = append(packageKeys, key)
+		}
+		emit(key, outputName, func(outputPath string) error {
+			return handlePackage(program, pkg, outputPath, reachable, opts, diagnostics)
+		})
+	}
+
+	key := ""
+	if cache != nil {
+		key = cache.sharedDefinitionKey(mainPkg.Pkg.Path(), packageKeys)
+	}
+	emit(key, "shared_definition.c", func(outputPath string) error {
+		return handleSharedDefinition(program, mainPkg, outputPath, reachable, opts, diagnostics)
+	})
 
 	waitGroup.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if count := diagnostics.count(); count > 0 {
+		diagnostics.report(os.Stderr)
+		return fmt.Errorf("%d unsupported constructs found", count)
+	}
+	return nil
 }
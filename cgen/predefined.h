@@ -1,13 +1,32 @@
 #include <assert.h>
 #include <complex.h>
+#include <math.h>
 #include <stdbool.h>
+#include <stddef.h>
 #include <stdint.h>
 #include <stdio.h>
+#include <stdlib.h>
 #include <string.h>
 
+// glibc defines CMPLX and CMPLXF for gcc only, although clang has the same
+// builtin.
+#ifndef CMPLX
+#define CMPLX(x, y) __builtin_complex((double)(x), (double)(y))
+#endif
+#ifndef CMPLXF
+#define CMPLXF(x, y) __builtin_complex((float)(x), (float)(y))
+#endif
+
 #define DECLARE_RUNTIME_API(name, param_type)                                  \
     FunctionObject(gox5_##name)(LightWeightThreadContext * ctx)
 
+// Reports code which cgen could not translate or which must not be reached,
+// and aborts. Unlike assert, it is kept by the release profile.
+static inline _Noreturn void gox5_abort(const char *message) {
+    fprintf(stderr, "fatal error: %s\n", message);
+    abort();
+}
+
 typedef struct GlobalContext GlobalContext;
 
 typedef struct {
@@ -36,7 +55,6 @@ DEFINE_BUILTIN_OBJECT_TYPE(Int8, int8_t);
 DEFINE_BUILTIN_OBJECT_TYPE(Int16, int16_t);
 DEFINE_BUILTIN_OBJECT_TYPE(Int32, int32_t);
 DEFINE_BUILTIN_OBJECT_TYPE(Int64, int64_t);
-DEFINE_BUILTIN_OBJECT_TYPE(String, const char *);
 DEFINE_BUILTIN_OBJECT_TYPE(UnsafePointer, void *);
 DEFINE_BUILTIN_OBJECT_TYPE(Uint, uintptr_t);
 DEFINE_BUILTIN_OBJECT_TYPE(Uint8, uint8_t);
@@ -45,6 +63,90 @@ DEFINE_BUILTIN_OBJECT_TYPE(Uint32, uint32_t);
 DEFINE_BUILTIN_OBJECT_TYPE(Uint64, uint64_t);
 DEFINE_BUILTIN_OBJECT_TYPE(Uintptr, uintptr_t);
 
+// Strings are not terminated by NUL, and substrings share the bytes.
+typedef struct {
+    struct {
+        const uint8_t *ptr;
+        uintptr_t len;
+    } raw;
+} StringObject;
+
+// Compares strings byte-wise, returning a negative value, 0 or a positive one
+// as lhs is less than, equal to or greater than rhs.
+static inline int gox5_string_compare(StringObject lhs, StringObject rhs) {
+    uintptr_t len = lhs.raw.len < rhs.raw.len ? lhs.raw.len : rhs.raw.len;
+    int result = len == 0 ? 0 : memcmp(lhs.raw.ptr, rhs.raw.ptr, len);
+    if (result != 0) {
+        return result;
+    }
+    return (lhs.raw.len > rhs.raw.len) - (lhs.raw.len < rhs.raw.len);
+}
+
+// Returns s[low:high], whose bounds are checked by the caller.
+static inline StringObject gox5_string_slice(StringObject s, uintptr_t low,
+                                             uintptr_t high) {
+    // ptr may be NULL for the empty string, to which no offset is added
+    const uint8_t *ptr = low == 0 ? s.raw.ptr : s.raw.ptr + low;
+    return (StringObject){.raw = {ptr, high - low}};
+}
+
+// Hashes of map keys, which the runtime mixes further with a seeded hasher.
+// Values equal by == must hash alike.
+static inline uintptr_t gox5_hash_combine(uintptr_t hash, uintptr_t value) {
+    return hash ^ (value + 0x9e3779b97f4a7c15u + (hash << 6) + (hash >> 2));
+}
+
+static inline uintptr_t gox5_hash_bytes(const uint8_t *ptr, uintptr_t len) {
+    uintptr_t hash = 0xcbf29ce484222325u;
+    for (uintptr_t i = 0; i < len; ++i) {
+        hash = (hash ^ ptr[i]) * 0x100000001b3u;
+    }
+    return hash;
+}
+
+// +0 and -0 hash alike, while NaN, which equals nothing, hashes differently
+// each time like with gc.
+uintptr_t gox5_map_nan_hash(void);
+
+static inline uintptr_t gox5_hash_float(double x) {
+    if (x == 0) {
+        return 0;
+    }
+    if (isnan(x)) {
+        return gox5_hash_combine(0, gox5_map_nan_hash());
+    }
+    uint64_t bits;
+    memcpy(&bits, &x, sizeof(bits));
+    return bits;
+}
+
+// Converts floats to integers like gc on amd64, whose CVTTSD2SL and CVTTSD2SQ
+// instructions yield the most negative integer for NaN and out of range
+// values. Narrower integers are truncated from int32, uint32 from int64.
+static inline int32_t gox5_float_to_int32(double x) {
+    if (x > -2147483649.0 && x < 2147483648.0) {
+        return (int32_t)x;
+    }
+    return INT32_MIN;
+}
+
+static inline int64_t gox5_float_to_int64(double x) {
+    if (x >= -9223372036854775808.0 && x < 9223372036854775808.0) {
+        return (int64_t)x;
+    }
+    return INT64_MIN;
+}
+
+// Values from 2^63 are converted with 2^63 subtracted, then added back by
+// setting the highest bit.
+static inline uint64_t gox5_float_to_uint64(double x) {
+    if (x < 9223372036854775808.0) {
+        return (uint64_t)gox5_float_to_int64(x);
+    }
+    return (uint64_t)gox5_float_to_int64(x - 9223372036854775808.0) |
+           ((uint64_t)1 << 63);
+}
+
 typedef struct {
     void *raw;
 } ChannelObject;
@@ -82,24 +184,40 @@ typedef struct {
 } LightWeightThreadContext;
 
 typedef struct {
-    const char *method_name;
-    FunctionObject method;
+    StringObject method_name;
+    StringObject package_path; // empty for exported names
+    StringObject signature;
+    FunctionObject method; // NULL in the tables of interface types
 } InterfaceTableEntry;
 
 typedef struct TypeInfo {
-    const char *name;
+    StringObject name;
     uintptr_t num_methods;
     const InterfaceTableEntry *interface_table;
-    void *is_equal;
+    void *is_equal; // NULL for types which are not comparable
     void *hash;
     uintptr_t size;
 } TypeInfo;
 
+// Set by the equality and hash functions on reaching a value whose dynamic
+// type is not comparable, for the caller to panic.
+extern const TypeInfo *gox5_uncomparable_type;
+
 typedef struct {
     void *receiver;
     TypeId type_id;
+    // methods of the dynamic type by slot, NULL for nil and empty interfaces
+    const FunctionObject *itab;
 } Interface;
 
+// Fills the itab slots of methods which were found unreachable, so that a call
+// through one of them fails with a message rather than jumping to NULL.
+static inline FunctionObject
+gox5_unreachable_method(LightWeightThreadContext *ctx) {
+    (void)ctx;
+    gox5_abort("call of a method which was found unreachable");
+}
+
 typedef struct {
     void *addr;
     uintptr_t size;
@@ -191,8 +309,8 @@ DECLARE_RUNTIME_API(defer_register, StackFrameDeferRegister);
 
 typedef struct {
     StackFrameCommon common;
-    Interface *interface;
-    StringObject method_name;
+    const Interface *interface;
+    FunctionObject method;
     uintptr_t result_size;
     uintptr_t num_arg_buffer_words;
     void *arg_buffer[0];
@@ -222,21 +340,6 @@ typedef struct {
 } StackFrameChannelSelect;
 DECLARE_RUNTIME_API(channel_select, StackFrameChannelSelect);
 
-typedef struct {
-    StackFrameCommon common;
-    void *result_ptr;
-    void *pointer;
-} StackFrameCheckNonNil;
-
-__attribute__((unused)) static FunctionObject
-gox5_check_non_nil(LightWeightThreadContext *ctx) {
-    StackFrameCheckNonNil *frame = (void *)ctx->stack_pointer;
-    assert(frame->pointer != NULL);
-    *((void **)frame->result_ptr) = frame->pointer;
-    ctx->stack_pointer = frame->common.prev_stack_pointer;
-    return frame->common.resume_func;
-}
-
 typedef struct {
     StackFrameCommon common;
     FunctionObject *result_ptr;
@@ -257,7 +360,7 @@ __attribute__((unused)) static FunctionObject
 gox5_complex64_new(LightWeightThreadContext *ctx) {
     StackFrameComplex64New *frame = (void *)ctx->stack_pointer;
     *frame->result_ptr =
-        (Complex64Object){.raw = frame->real.raw + frame->imaginary.raw * I};
+        (Complex64Object){.raw = CMPLXF(frame->real.raw, frame->imaginary.raw)};
     ctx->stack_pointer = frame->common.prev_stack_pointer;
     return frame->common.resume_func;
 }
@@ -289,7 +392,7 @@ __attribute__((unused)) static FunctionObject
 gox5_complex128_new(LightWeightThreadContext *ctx) {
     StackFrameComplex128New *frame = (void *)ctx->stack_pointer;
     *frame->result_ptr =
-        (Complex128Object){.raw = frame->real.raw + frame->imaginary.raw * I};
+        (Complex128Object){.raw = CMPLX(frame->real.raw, frame->imaginary.raw)};
     ctx->stack_pointer = frame->common.prev_stack_pointer;
     return frame->common.resume_func;
 }
@@ -315,22 +418,22 @@ typedef struct {
     Interface *result_ptr;
     const void *receiver;
     TypeId type_id;
+    const FunctionObject *itab;
 } StackFrameInterfaceNew;
 DECLARE_RUNTIME_API(interface_new, StackFrameInterfaceNew);
 
 typedef struct {
     StackFrameCommon common;
-    void *result_ptr;
-    Interface *interface;
-    StringObject method_name;
-    uintptr_t num_arg_buffer_words;
-    void *arg_buffer[0];
-} StackFrameInterfaceInvoke;
-DECLARE_RUNTIME_API(interface_invoke, StackFrameInterfaceInvoke);
+    Interface *result_ptr;
+    const Interface *interface;
+    TypeId to_type;
+} StackFrameInterfaceChange;
+DECLARE_RUNTIME_API(interface_change, StackFrameInterfaceChange);
 
 typedef struct {
     StackFrameCommon common;
-    Interface *interface;
+    const Interface *interface;
+    TypeId from_type;
     TypeId to_type;
     void *value;
     bool *success;
@@ -340,7 +443,7 @@ DECLARE_RUNTIME_API(interface_convert_to_concrete_type,
 
 typedef struct {
     StackFrameCommon common;
-    Interface *interface;
+    const Interface *interface;
     TypeId to_type;
     void *value;
     bool *success;
@@ -437,11 +540,19 @@ typedef struct {
             int64_t as_integer;
             double as_float;
             const void* as_pointer;
-        } data[2];
+        } data[3];
     } entry_buffer[0];
 } StackFramePrint;
 
 static void gox5_print_helper(double val) {
+    if (isnan(val)) {
+        fprintf(stderr, "NaN");
+        return;
+    }
+    if (isinf(val)) {
+        fprintf(stderr, "%s", val > 0 ? "+Inf" : "-Inf");
+        return;
+    }
     char buf[20];
     int len = snprintf(buf, sizeof(buf) / sizeof(buf[0]), "%+.6e", val);
     int len_e = 0;
@@ -477,11 +588,22 @@ gox5_print(LightWeightThreadContext *ctx) {
             fprintf(stderr, "%s", frame->entry_buffer[i].data[0].as_integer ? "true" : "false");
         } else if(strcmp(format, "%f")==0){
             gox5_print_helper(frame->entry_buffer[i].data[0].as_float);
+        } else if(strcmp(format, "%s")==0){
+            fwrite(frame->entry_buffer[i].data[0].as_pointer, 1, frame->entry_buffer[i].data[1].as_integer, stderr);
         } else if(strcmp(format, "%i")==0){
             fprintf(stderr, "(");
             gox5_print_helper(frame->entry_buffer[i].data[0].as_float);
             gox5_print_helper(frame->entry_buffer[i].data[1].as_float);
             fprintf(stderr, "i)");
+        } else if(strcmp(format, "%p")==0){
+            fprintf(stderr, "0x%lx", (uintptr_t)frame->entry_buffer[i].data[0].as_pointer);
+        } else if(strcmp(format, "%S")==0){
+            fprintf(stderr, "[%ld/%ld]0x%lx", frame->entry_buffer[i].data[0].as_integer,
+                    frame->entry_buffer[i].data[1].as_integer,
+                    (uintptr_t)frame->entry_buffer[i].data[2].as_pointer);
+        } else if(strcmp(format, "%I")==0){
+            fprintf(stderr, "(0x%lx,0x%lx)", (uintptr_t)frame->entry_buffer[i].data[0].as_pointer,
+                    (uintptr_t)frame->entry_buffer[i].data[1].as_pointer);
         } else{
             fprintf(stderr, format, frame->entry_buffer[i].data[0].as_integer);
         }
@@ -534,6 +656,75 @@ typedef struct {
 } StackFramePanicRaise;
 DECLARE_RUNTIME_API(panic_raise, StackFramePanicRaise);
 
+typedef enum {
+    BoundsIndex,
+    BoundsSliceAlen,
+    BoundsSliceAcap,
+    BoundsSliceB,
+    BoundsSlice3Alen,
+    BoundsSlice3Acap,
+    BoundsSlice3B,
+    BoundsSlice3C,
+} BoundsCode;
+
+typedef struct {
+    StackFrameCommon common;
+    int64_t x;
+    intptr_t y;
+    bool signed_x;
+    uintptr_t code;
+} StackFramePanicBounds;
+DECLARE_RUNTIME_API(panic_bounds, StackFramePanicBounds);
+
+// Compares the operands of bounds checks as unsigned 64-bit values, which
+// also keeps narrow operands from being reported as always in range.
+static inline bool gox5_bounds_index_fails(uint64_t x, uint64_t y) {
+    return x >= y;
+}
+
+static inline bool gox5_bounds_slice_fails(uint64_t x, uint64_t y) {
+    return x > y;
+}
+
+typedef struct {
+    StackFrameCommon common;
+} StackFramePanicNilDereference;
+DECLARE_RUNTIME_API(panic_nil_dereference, StackFramePanicNilDereference);
+
+typedef struct {
+    StackFrameCommon common;
+} StackFramePanicDivide;
+DECLARE_RUNTIME_API(panic_divide, StackFramePanicDivide);
+
+typedef struct {
+    StackFrameCommon common;
+} StackFramePanicShift;
+DECLARE_RUNTIME_API(panic_shift, StackFramePanicShift);
+
+typedef struct {
+    StackFrameCommon common;
+    TypeId type_id;
+    bool hashing;
+} StackFramePanicUncomparable;
+DECLARE_RUNTIME_API(panic_uncomparable, StackFramePanicUncomparable);
+
+typedef struct {
+    StackFrameCommon common;
+    void *result_ptr;
+    void *pointer;
+} StackFrameCheckNonNil;
+
+__attribute__((unused)) static FunctionObject
+gox5_check_non_nil(LightWeightThreadContext *ctx) {
+    StackFrameCheckNonNil *frame = (void *)ctx->stack_pointer;
+    if (frame->pointer == NULL) {
+        return gox5_panic_nil_dereference(ctx);
+    }
+    *((void **)frame->result_ptr) = frame->pointer;
+    ctx->stack_pointer = frame->common.prev_stack_pointer;
+    return frame->common.resume_func;
+}
+
 typedef struct {
     StackFrameCommon common;
     Interface *result_ptr;
@@ -551,26 +742,10 @@ DECLARE_RUNTIME_API(lwt_spawn, StackFrameLwtSpawn);
 
 typedef struct {
     StackFrameCommon common;
-    Interface *interface;
-    StringObject method_name;
+    const Interface *interface;
+    FunctionObject method;
     uintptr_t result_size;
     uintptr_t num_arg_buffer_words;
     void *arg_buffer[0];
 } StackFrameLwtSpawnInvoke;
 DECLARE_RUNTIME_API(lwt_spawn_invoke, StackFrameLwtSpawnInvoke);
-
-typedef struct {
-    StackFrameCommon common;
-    IntObject *result_ptr;
-    StringObject string;
-} StackFrameStringLength;
-DECLARE_RUNTIME_API(string_length, StackFrameStringLength);
-
-typedef struct {
-    StackFrameCommon common;
-    StringObject *result_ptr;
-    StringObject base;
-    intptr_t low;
-    intptr_t high;
-} StackFrameStringSubstr;
-DECLARE_RUNTIME_API(string_substr, StackFrameStringSubstr);
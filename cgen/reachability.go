@@ -0,0 +1,88 @@
+package main
+
+import (
+	"go/types"
+
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/ssa"
+)
+
+// analyzeReachability returns the functions reachable from the entry points
+// of the main package, including the entry points themselves.
+//
+// Every method of a type converted to an interface is kept, as it is
+// referred to from the interface table of the type, and so is every function
+// used as a value, as the generated code refers to it even if RTA finds no
+// call of it. Since those functions may convert further types, the analysis
+// is repeated until no new function is found.
+func analyzeReachability(program *ssa.Program, mainPkg *ssa.Package) map[*ssa.Function]struct{} {
+	roots := []*ssa.Function{
+		mainPkg.Members["init"].(*ssa.Function),
+		mainPkg.Members["main"].(*ssa.Function),
+	}
+	rootSet := make(map[*ssa.Function]struct{})
+	for _, root := range roots {
+		rootSet[root] = struct{}{}
+	}
+
+	for {
+		result := rta.Analyze(roots, false)
+
+		found := false
+		addRoot := func(function *ssa.Function) {
+			if _, ok := rootSet[function]; ok {
+				return
+			}
+			rootSet[function] = struct{}{}
+			roots = append(roots, function)
+			found = true
+		}
+		result.RuntimeTypes.Iterate(func(typ types.Type, _ interface{}) {
+			if _, ok := typ.Underlying().(*types.Interface); ok {
+				return
+			}
+			methodSet := program.MethodSets.MethodSet(typ)
+			for i := 0; i < methodSet.Len(); i++ {
+				if function := program.MethodValue(methodSet.At(i)); function != nil {
+					addRoot(function)
+				}
+			}
+		})
+		addOperands := func(function *ssa.Function) {
+			for _, basicBlock := range function.Blocks {
+				for _, instr := range basicBlock.Instrs {
+					for _, operand := range instr.Operands(nil) {
+						if value, ok := (*operand).(*ssa.Function); ok {
+							addRoot(value)
+						}
+					}
+				}
+			}
+		}
+		for _, root := range roots {
+			addOperands(root)
+		}
+		for function := range result.Reachable {
+			addOperands(function)
+		}
+		if found {
+			continue
+		}
+
+		reachable := rootSet
+		for function := range result.Reachable {
+			reachable[function] = struct{}{}
+		}
+		return reachable
+	}
+}
+
+// isReachable reports whether the function has to be emitted. All functions
+// are emitted when dead code elimination is disabled.
+func (ctx *Context) isReachable(function *ssa.Function) bool {
+	if ctx.reachable == nil {
+		return true
+	}
+	_, ok := ctx.reachable[function]
+	return ok
+}
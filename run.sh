@@ -15,12 +15,7 @@ dir_name=$(cd `dirname $1` && pwd)
 base_name=`basename $1`
 src=$dir_name/$base_name
 cd cgen
-go run main.go -b ../$build_directory -i $src
-cd ..
-
-cd $build_directory
-ln -s ../cgen/predefined.h predefined.h
-make -j
+go run . build -b ../$build_directory -runtime ../target -o ../$bin_file_name $src
 cd ..
 
 $bin_file_name
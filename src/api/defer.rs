@@ -1,9 +1,9 @@
 use std::mem;
 use std::ptr;
 
+use crate::api::panic::raise_nil_dereference_error;
 use crate::defer_stack::DeferStackEntry;
 use crate::object::interface::Interface;
-use crate::object::string::StringObject;
 use crate::word_chunk::WordChunk;
 use crate::FunctionObject;
 use crate::LightWeightThreadContext;
@@ -60,17 +60,23 @@ pub extern "C" fn gox5_defer_register(ctx: &mut LightWeightThreadContext) -> Fun
 struct StackFrameDeferRegisterInvoke<'a> {
     common: StackFrameCommon,
     interface: &'a Interface,
-    method_name: StringObject,
+    method: FunctionObject,
     result_size: usize,
     args: WordChunk,
 }
 
 #[no_mangle]
 pub extern "C" fn gox5_defer_register_invoke(ctx: &mut LightWeightThreadContext) -> FunctionObject {
+    if ctx
+        .stack_frame::<StackFrameDeferRegisterInvoke>()
+        .interface
+        .is_nil()
+    {
+        return raise_nil_dereference_error(ctx);
+    }
     register(ctx, |ctx| {
         let frame = ctx.stack_frame::<StackFrameDeferRegisterInvoke>();
-        let method = frame.interface.search(frame.method_name.clone());
-        let func = method.unwrap();
+        let func = frame.method.clone();
         let result_size = frame.result_size;
         let args = &frame.args;
         (func, result_size, args)
@@ -92,6 +98,11 @@ pub extern "C" fn gox5_defer_execute(ctx: &mut LightWeightThreadContext) -> Func
         None => return ctx.pop_frame(),
     };
 
+    // A nil function is deferred without error, and panics when it is called.
+    if entry.func().is_null() {
+        return raise_nil_dereference_error(ctx);
+    }
+
     // Keep the stack frame at the time it is called by user function.
     let prev_stack_pointer = ctx.stack_pointer();
     ctx.grow_stack(mem::size_of::<StackFrameDeferExecute>());
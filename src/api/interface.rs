@@ -1,9 +1,8 @@
 use std::ptr;
 
-use crate::object::interface::Interface;
-use crate::object::string::StringObject;
+use crate::api::panic::raise_type_assertion_error;
+use crate::object::interface::{Interface, Itab};
 use crate::type_id::TypeId;
-use crate::word_chunk::WordChunk;
 use crate::FunctionObject;
 use crate::LightWeightThreadContext;
 use crate::ObjectPtr;
@@ -15,6 +14,7 @@ struct StackFrameInterfaceNew<'a> {
     result_ptr: &'a mut Interface,
     receiver: ObjectPtr,
     type_id: TypeId,
+    itab: Itab,
 }
 
 #[no_mangle]
@@ -34,7 +34,7 @@ pub extern "C" fn gox5_interface_new(ctx: &mut LightWeightThreadContext) -> Func
         ObjectPtr(ptr)
     };
 
-    let interface = Interface::new(receiver, frame.type_id);
+    let interface = Interface::new(receiver, frame.type_id).with_itab(frame.itab);
 
     let frame = ctx.stack_frame_mut::<StackFrameInterfaceNew>();
     *frame.result_ptr = interface;
@@ -42,10 +42,23 @@ pub extern "C" fn gox5_interface_new(ctx: &mut LightWeightThreadContext) -> Func
     ctx.pop_frame()
 }
 
+fn type_name(type_id: &TypeId) -> String {
+    String::from_utf8_lossy(type_id.name().as_bytes()).into_owned()
+}
+
+fn dynamic_type_name(interface: &Interface) -> String {
+    if interface.is_nil() {
+        "nil".to_string()
+    } else {
+        type_name(interface.type_id())
+    }
+}
+
 #[repr(C)]
 struct StackFrameInterfaceConvertToConcreteType<'a> {
     common: StackFrameCommon,
     interface: &'a Interface,
+    from_type: TypeId,
     to_type: TypeId,
     value: ObjectPtr,
     success: ObjectPtr,
@@ -69,7 +82,13 @@ pub extern "C" fn gox5_interface_convert_to_concrete_type(
         }
     } else {
         if frame.success.is_null() {
-            unimplemented!()
+            let message = format!(
+                "{} is {}, not {}",
+                type_name(&frame.from_type),
+                dynamic_type_name(frame.interface),
+                type_name(&frame.to_type)
+            );
+            return raise_type_assertion_error(ctx, &message);
         }
 
         unsafe {
@@ -97,20 +116,40 @@ struct StackFrameInterfaceConvertToInterface<'a> {
 pub extern "C" fn gox5_interface_convert_to_interface(
     ctx: &mut LightWeightThreadContext,
 ) -> FunctionObject {
-    let frame = ctx.stack_frame_mut::<StackFrameInterfaceConvertToInterface>();
+    let frame = ctx.stack_frame::<StackFrameInterfaceConvertToInterface>();
 
-    let success = frame.to_type.interface_table().iter().all(|entry| {
-        frame
-            .interface
-            .search(entry.method_name().clone())
-            .is_some()
-    });
+    let itab = if frame.interface.is_nil() {
+        None
+    } else {
+        let to_type = frame.to_type;
+        let type_id = *frame.interface.type_id();
+        ctx.global_context()
+            .process(|mut global_context| global_context.itab(to_type, type_id))
+    };
+    let success = itab.is_some();
 
-    if success {
-        *frame.value.as_mut::<Interface>() = frame.interface.clone();
+    let frame = ctx.stack_frame_mut::<StackFrameInterfaceConvertToInterface>();
+    if let Some(itab) = itab {
+        *frame.value.as_mut::<Interface>() = frame.interface.with_itab(itab);
     } else {
         if frame.success.is_null() {
-            unimplemented!()
+            let message = if frame.interface.is_nil() {
+                format!("interface is nil, not {}", type_name(&frame.to_type))
+            } else {
+                let missing_method = frame
+                    .to_type
+                    .interface_table()
+                    .iter()
+                    .find(|entry| !frame.interface.implements(entry))
+                    .unwrap();
+                format!(
+                    "{} is not {}: missing method {}",
+                    dynamic_type_name(frame.interface),
+                    type_name(&frame.to_type),
+                    String::from_utf8_lossy(missing_method.method_name().as_bytes())
+                )
+            };
+            return raise_type_assertion_error(ctx, &message);
         }
         *frame.value.as_mut::<Interface>() = Interface::nil();
     }
@@ -123,38 +162,31 @@ pub extern "C" fn gox5_interface_convert_to_interface(
 }
 
 #[repr(C)]
-struct StackFrameInterfaceInvoke<'a> {
+struct StackFrameInterfaceChange<'a> {
     common: StackFrameCommon,
-    result_ptr: Option<&'a mut ()>,
+    result_ptr: &'a mut Interface,
     interface: &'a Interface,
-    method_name: StringObject,
-    args: WordChunk,
+    to_type: TypeId,
 }
 
 #[no_mangle]
-pub extern "C" fn gox5_interface_invoke(ctx: &mut LightWeightThreadContext) -> FunctionObject {
-    let frame = ctx.stack_frame::<StackFrameInterfaceInvoke>();
-    let method = frame.interface.search(frame.method_name.clone());
-    let next_func = method.unwrap();
-
-    let args = ctx.global_context().process(|mut global_context| {
-        let allocator = global_context.allocator();
-        frame.args.duplicate(allocator)
-    });
-
-    let result_pointer = frame.result_ptr.as_ref().map(|p| (*p) as *const ());
-
-    let current_stack_pointer = ctx.stack_pointer();
-    let resume_func = ctx.pop_frame();
-    let prev_stack_pointer = ctx.stack_pointer();
-    ctx.grow_stack((current_stack_pointer as usize) - (prev_stack_pointer as usize));
-
-    ctx.push_frame(
-        prev_stack_pointer,
-        result_pointer,
-        unsafe { args.as_ref().as_slice() },
-        resume_func,
-    );
-
-    next_func
+pub extern "C" fn gox5_interface_change(ctx: &mut LightWeightThreadContext) -> FunctionObject {
+    let frame = ctx.stack_frame::<StackFrameInterfaceChange>();
+
+    let interface = if frame.interface.is_nil() {
+        Interface::nil()
+    } else {
+        let to_type = frame.to_type;
+        let type_id = *frame.interface.type_id();
+        let itab = ctx
+            .global_context()
+            .process(|mut global_context| global_context.itab(to_type, type_id));
+        // the interface has all the methods of the one converted from
+        frame.interface.with_itab(itab.unwrap())
+    };
+
+    let frame = ctx.stack_frame_mut::<StackFrameInterfaceChange>();
+    *frame.result_ptr = interface;
+
+    ctx.pop_frame()
 }
@@ -1,7 +1,7 @@
+use crate::api::panic::raise_nil_dereference_error;
 use crate::create_light_weight_thread_context;
 use crate::light_weight_thread::LightWeightThreadContext;
 use crate::object::interface::Interface;
-use crate::object::string::StringObject;
 use crate::word_chunk::WordChunk;
 use crate::FunctionObject;
 use crate::StackFrameCommon;
@@ -60,17 +60,23 @@ pub extern "C" fn gox5_lwt_spawn(ctx: &mut LightWeightThreadContext) -> Function
 struct StackFrameLwtSpawnInvoke<'a> {
     common: StackFrameCommon,
     interface: &'a Interface,
-    method_name: StringObject,
+    method: FunctionObject,
     result_size: usize,
     args: WordChunk,
 }
 
 #[no_mangle]
 pub extern "C" fn gox5_lwt_spawn_invoke(ctx: &mut LightWeightThreadContext) -> FunctionObject {
+    if ctx
+        .stack_frame::<StackFrameLwtSpawnInvoke>()
+        .interface
+        .is_nil()
+    {
+        return raise_nil_dereference_error(ctx);
+    }
     spawn(ctx, |ctx| {
         let frame = ctx.stack_frame::<StackFrameLwtSpawnInvoke>();
-        let method = frame.interface.search(frame.method_name.clone());
-        let entry_func = method.unwrap();
+        let entry_func = frame.method.clone();
         let result_size = frame.result_size;
         let args = &frame.args;
         (entry_func, result_size, args)
@@ -1,5 +1,6 @@
 use std::mem;
 use std::ptr;
+use std::sync::atomic::{AtomicUsize, Ordering};
 
 use crate::object::map::MapObject;
 use crate::type_id::TypeId;
@@ -95,9 +96,8 @@ pub extern "C" fn gox5_map_get(ctx: &mut LightWeightThreadContext) -> FunctionOb
     let value = frame.value.clone();
     let found = map.get(key, value);
 
-    if frame.found.is_null() {
-        assert!(found);
-    } else {
+    // a missing key yields the zero value, which get has stored
+    if !frame.found.is_null() {
         let frame = ctx.stack_frame_mut::<StackFrameMapGet>();
         *frame.found.as_mut() = found;
     }
@@ -190,3 +190,12 @@ pub extern "C" fn gox5_map_set(ctx: &mut LightWeightThreadContext) -> FunctionOb
 
     ctx.pop_frame()
 }
+
+static NAN_COUNT: AtomicUsize = AtomicUsize::new(0);
+
+// Hashes of NaN keys, which differ each time. The counter is kept here rather
+// than in the generated code, so that it is shared by all the packages.
+#[no_mangle]
+pub extern "C" fn gox5_map_nan_hash() -> usize {
+    NAN_COUNT.fetch_add(1, Ordering::Relaxed) + 1
+}
@@ -2,6 +2,8 @@ use std::mem;
 use std::process;
 
 use crate::object::interface::Interface;
+use crate::object::runtime_error::RuntimeError;
+use crate::type_id::TypeId;
 use crate::FunctionObject;
 use crate::LightWeightThreadContext;
 use crate::StackFrameCommon;
@@ -55,10 +57,134 @@ extern "C" fn panic_raise_body(ctx: &mut LightWeightThreadContext) -> FunctionOb
 pub extern "C" fn gox5_panic_raise(ctx: &mut LightWeightThreadContext) -> FunctionObject {
     let frame = ctx.stack_frame::<StackFramePanicRaise>();
     let data = frame.value.clone();
-    ctx.enter_panic(data);
+    raise(ctx, data)
+}
+
+// Unwinding only refers to the common part of the current frame, so that any
+// runtime API can raise a panic from its own frame.
+fn raise(ctx: &mut LightWeightThreadContext, value: Interface) -> FunctionObject {
+    ctx.enter_panic(value);
     FunctionObject::from_user_function(UserFunction::new(panic_raise_body))
 }
 
+fn raise_error(ctx: &mut LightWeightThreadContext, message: &str) -> FunctionObject {
+    let value = ctx.global_context().process(|mut global_context| {
+        RuntimeError::new_interface(message, global_context.allocator())
+    });
+    raise(ctx, value)
+}
+
+/// Raises a panic with a runtime error, as gc does when a check fails. The
+/// message is prefixed by "runtime error: " like with gc.
+pub(crate) fn raise_runtime_error(
+    ctx: &mut LightWeightThreadContext,
+    message: &str,
+) -> FunctionObject {
+    raise_error(ctx, &format!("runtime error: {}", message))
+}
+
+/// Raises a panic for a failed type assertion. Like runtime.TypeAssertionError
+/// of gc, the error is a runtime.Error but the message is prefixed by
+/// "interface conversion: " instead.
+pub(crate) fn raise_type_assertion_error(
+    ctx: &mut LightWeightThreadContext,
+    message: &str,
+) -> FunctionObject {
+    raise_error(ctx, &format!("interface conversion: {}", message))
+}
+
+pub(crate) fn raise_nil_dereference_error(ctx: &mut LightWeightThreadContext) -> FunctionObject {
+    raise_runtime_error(ctx, "invalid memory address or nil pointer dereference")
+}
+
+// The formats of runtime.boundsError, indexed by the code of the check which
+// failed. x is the index, y the length, the capacity or the next index.
+const BOUNDS_ERROR_FORMATS: [&str; 8] = [
+    "index out of range [%x] with length %y",
+    "slice bounds out of range [:%x] with length %y",
+    "slice bounds out of range [:%x] with capacity %y",
+    "slice bounds out of range [%x:%y]",
+    "slice bounds out of range [::%x] with length %y",
+    "slice bounds out of range [::%x] with capacity %y",
+    "slice bounds out of range [:%x:%y]",
+    "slice bounds out of range [%x:%y:]",
+];
+
+// The formats used when x is negative, which is then out of range by itself.
+const BOUNDS_NEGATIVE_ERROR_FORMATS: [&str; 8] = [
+    "index out of range [%x]",
+    "slice bounds out of range [:%x]",
+    "slice bounds out of range [:%x]",
+    "slice bounds out of range [%x:]",
+    "slice bounds out of range [::%x]",
+    "slice bounds out of range [::%x]",
+    "slice bounds out of range [:%x:]",
+    "slice bounds out of range [%x::]",
+];
+
+fn bounds_error_message(code: usize, x: i64, signed: bool, y: isize) -> String {
+    let (format, x) = if signed {
+        if x < 0 {
+            (BOUNDS_NEGATIVE_ERROR_FORMATS[code], x.to_string())
+        } else {
+            (BOUNDS_ERROR_FORMATS[code], x.to_string())
+        }
+    } else {
+        (BOUNDS_ERROR_FORMATS[code], (x as u64).to_string())
+    };
+    format.replace("%x", &x).replace("%y", &y.to_string())
+}
+
+#[repr(C)]
+struct StackFramePanicBounds {
+    common: StackFrameCommon,
+    x: i64,
+    y: isize,
+    signed_x: bool,
+    code: usize,
+}
+
+#[no_mangle]
+pub extern "C" fn gox5_panic_bounds(ctx: &mut LightWeightThreadContext) -> FunctionObject {
+    let frame = ctx.stack_frame::<StackFramePanicBounds>();
+    let message = bounds_error_message(frame.code, frame.x, frame.signed_x, frame.y);
+    raise_runtime_error(ctx, &message)
+}
+
+#[no_mangle]
+pub extern "C" fn gox5_panic_nil_dereference(ctx: &mut LightWeightThreadContext) -> FunctionObject {
+    raise_nil_dereference_error(ctx)
+}
+
+#[no_mangle]
+pub extern "C" fn gox5_panic_divide(ctx: &mut LightWeightThreadContext) -> FunctionObject {
+    raise_runtime_error(ctx, "integer divide by zero")
+}
+
+#[no_mangle]
+pub extern "C" fn gox5_panic_shift(ctx: &mut LightWeightThreadContext) -> FunctionObject {
+    raise_runtime_error(ctx, "negative shift amount")
+}
+
+#[repr(C)]
+struct StackFramePanicUncomparable {
+    common: StackFrameCommon,
+    type_id: TypeId,
+    hashing: bool,
+}
+
+#[no_mangle]
+pub extern "C" fn gox5_panic_uncomparable(ctx: &mut LightWeightThreadContext) -> FunctionObject {
+    let frame = ctx.stack_frame::<StackFramePanicUncomparable>();
+    let name = String::from_utf8_lossy(frame.type_id.name().as_bytes());
+    let message = if frame.hashing {
+        format!("hash of unhashable type {}", name)
+    } else {
+        format!("comparing uncomparable type {}", name)
+    };
+    raise_runtime_error(ctx, &message)
+}
+
 #[repr(C)]
 struct StackFramePanicRecover<'a> {
     common: StackFrameCommon,
@@ -76,3 +202,36 @@ pub extern "C" fn gox5_panic_recover(ctx: &mut LightWeightThreadContext) -> Func
     *frame.result_ptr = result;
     ctx.pop_frame()
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_bounds_error_message() {
+        assert_eq!(
+            bounds_error_message(0, 5, true, 3),
+            "index out of range [5] with length 3"
+        );
+        assert_eq!(
+            bounds_error_message(0, -1, true, 3),
+            "index out of range [-1]"
+        );
+        assert_eq!(
+            bounds_error_message(0, -1, false, 3),
+            "index out of range [18446744073709551615] with length 3"
+        );
+        assert_eq!(
+            bounds_error_message(2, 7, true, 4),
+            "slice bounds out of range [:7] with capacity 4"
+        );
+        assert_eq!(
+            bounds_error_message(3, 3, true, 2),
+            "slice bounds out of range [3:2]"
+        );
+        assert_eq!(
+            bounds_error_message(7, -2, true, 1),
+            "slice bounds out of range [-2::]"
+        );
+    }
+}
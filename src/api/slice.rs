@@ -4,6 +4,7 @@ use std::mem;
 use std::ptr;
 
 use crate::object::slice::SliceObject;
+use crate::object::string::decode_rune;
 use crate::object::string::StringObject;
 use crate::type_id::TypeId;
 use crate::FunctionObject;
@@ -26,27 +27,41 @@ pub extern "C" fn gox5_slice_from_string(ctx: &mut LightWeightThreadContext) ->
     let elem_size = frame.type_id.size();
     assert!(elem_size == mem::size_of::<u8>() || elem_size == mem::size_of::<u32>());
 
-    let len = frame.src.len_in_bytes();
+    let runes = if elem_size == mem::size_of::<u8>() {
+        None
+    } else {
+        let mut bytes = frame.src.as_bytes();
+        let mut runes = Vec::new();
+        while !bytes.is_empty() {
+            let (ch, width) = decode_rune(bytes);
+            runes.push(ch);
+            bytes = &bytes[width..];
+        }
+        Some(runes)
+    };
+
+    let len = runes
+        .as_ref()
+        .map_or(frame.src.len_in_bytes(), |runes| runes.len());
     let buffer_size = len * elem_size;
     let ptr = ctx
         .global_context()
         .process(|mut global_context| global_context.allocator().allocate(buffer_size, |_ptr| {}));
 
     let mut result = SliceObject::new(ptr, len, len);
-    if frame.type_id.size() == mem::size_of::<u8>() {
-        result
-            .as_bytes_mut(elem_size)
-            .clone_from_slice(frame.src.as_bytes());
-    } else {
-        let s = frame.src.to_str().unwrap();
+    if let Some(runes) = runes {
         iter::zip(
             result.as_bytes_mut(elem_size)[..buffer_size].chunks_mut(elem_size),
-            s.chars(),
+            runes,
         )
         .for_each(|(dst_bytes, ch)| {
             let src_bytes = (ch as u32).to_le_bytes();
             dst_bytes.clone_from_slice(&src_bytes);
         });
+    } else {
+        result
+            .as_bytes_mut(elem_size)
+            .clone_from_slice(frame.src.as_bytes());
     }
 
     let frame = ctx.stack_frame_mut::<StackFrameSliceFromString>();
@@ -80,7 +95,7 @@ fn reallocate_slice(
 
         result
     } else {
-        base.duplicate_extend(base.size())
+        base.duplicate_extend(new_size - base.size())
     };
 
     let result_slice = result.as_bytes_mut(elem_size);
@@ -1,6 +1,7 @@
 use std::mem;
 
 use crate::object::slice::SliceObject;
+use crate::object::string::decode_rune;
 use crate::object::string::StringObject;
 use crate::FunctionObject;
 use crate::LightWeightThreadContext;
@@ -133,24 +134,6 @@ pub extern "C" fn gox5_string_append(ctx: &mut LightWeightThreadContext) -> Func
     ctx.pop_frame()
 }
 
-#[repr(C)]
-struct StackFrameStringLength<'a> {
-    common: StackFrameCommon,
-    result_ptr: &'a mut isize,
-    string: StringObject,
-}
-
-#[no_mangle]
-pub extern "C" fn gox5_string_length(ctx: &mut LightWeightThreadContext) -> FunctionObject {
-    let frame = ctx.stack_frame::<StackFrameStringLength>();
-    let result = isize::try_from(frame.string.len_in_bytes()).unwrap();
-
-    let frame = ctx.stack_frame_mut::<StackFrameStringLength>();
-    *frame.result_ptr = result;
-
-    ctx.pop_frame()
-}
-
 #[repr(C)]
 struct StackFrameStringNext<'a> {
     common: StackFrameCommon,
@@ -161,72 +144,27 @@ struct StackFrameStringNext<'a> {
     count: &'a mut usize,
 }
 
+/// Advances the iteration of a range loop over a string, where count is the
+/// byte offset of the next rune.
 #[no_mangle]
 pub extern "C" fn gox5_string_next(ctx: &mut LightWeightThreadContext) -> FunctionObject {
     let frame = ctx.stack_frame_mut::<StackFrameStringNext>();
 
-    let s = frame.string.to_str().unwrap();
+    let bytes = frame.string.as_bytes();
     let index = *frame.count;
-    if let Some(c) = s.chars().nth(index) {
+    if index < bytes.len() {
+        let (ch, width) = decode_rune(&bytes[index..]);
         if let Some(p) = frame.index.as_mut() {
             **p = index as isize;
         }
         if let Some(p) = frame.rune.as_mut() {
-            **p = c as i32;
+            **p = ch as i32;
         }
         *frame.found = true;
-        *frame.count = index + 1;
+        *frame.count = index + width;
     } else {
         *frame.found = false;
     }
 
     ctx.pop_frame()
 }
-
-#[repr(C)]
-struct StackFrameStringSubstr<'a> {
-    common: StackFrameCommon,
-    result_ptr: &'a mut StringObject,
-    base: StringObject,
-    low: isize,
-    high: isize,
-}
-
-#[no_mangle]
-pub extern "C" fn gox5_string_substr(ctx: &mut LightWeightThreadContext) -> FunctionObject {
-    let frame = ctx.stack_frame::<StackFrameStringSubstr>();
-
-    let low = {
-        let low = frame.low;
-        if low < 0 {
-            assert_eq!(low, -1);
-            0
-        } else {
-            low as usize
-        }
-    };
-
-    let high = {
-        let high = frame.high;
-        if high < 0 {
-            assert_eq!(high, -1);
-            frame.base.len_in_bytes()
-        } else {
-            high as usize
-        }
-    };
-
-    assert!(low <= high);
-    let len = high - low;
-
-    let mut builder = ctx
-        .global_context()
-        .process(|mut global_context| StringObject::builder(len, global_context.allocator()));
-
-    builder.append_bytes(&frame.base.as_bytes()[low..high]);
-
-    let frame = ctx.stack_frame_mut::<StackFrameStringSubstr>();
-    *frame.result_ptr = builder.build();
-
-    ctx.pop_frame()
-}
@@ -1,6 +1,8 @@
-use std::collections::VecDeque;
+use std::collections::{HashMap, VecDeque};
 use std::sync::{Arc, Mutex, MutexGuard};
 
+use crate::object::interface::Itab;
+use crate::type_id::TypeId;
 use crate::LightWeightThreadContext;
 use crate::ObjectAllocator;
 
@@ -8,6 +10,7 @@ pub struct GlobalContext {
     created_light_weight_thread_count: usize,
     allocator: Box<dyn ObjectAllocator>,
     run_queue: VecDeque<LightWeightThreadContext>,
+    itabs: HashMap<(TypeId, TypeId), Option<Itab>>,
 }
 
 impl GlobalContext {
@@ -16,6 +19,7 @@ impl GlobalContext {
             created_light_weight_thread_count: 0,
             allocator,
             run_queue: VecDeque::new(),
+            itabs: HashMap::new(),
         }
     }
 
@@ -25,6 +29,16 @@ impl GlobalContext {
         id
     }
 
+    /// Returns the itab of the interface type for the concrete type, built on
+    /// the first conversion between them, or None if the type does not
+    /// implement the interface.
+    pub(crate) fn itab(&mut self, interface_type: TypeId, type_id: TypeId) -> Option<Itab> {
+        *self
+            .itabs
+            .entry((interface_type, type_id))
+            .or_insert_with(|| Itab::new(&interface_type, &type_id))
+    }
+
     pub fn allocator(&mut self) -> &mut dyn ObjectAllocator {
         &mut *self.allocator
     }
@@ -36,6 +36,10 @@ impl FunctionObject {
         FunctionObject(user_function.0 as *const ())
     }
 
+    pub(crate) const fn from_static_user_function(user_function: UserFunctionInner) -> Self {
+        FunctionObject(user_function as *const ())
+    }
+
     pub fn from_closure_layout_ptr(closure_layout_ptr: *const ()) -> Self {
         let addr = closure_layout_ptr as usize;
         let flag = 1 << 63;
@@ -47,6 +51,10 @@ impl FunctionObject {
         FunctionObject(ptr::null_mut())
     }
 
+    pub fn is_null(&self) -> bool {
+        self.0.is_null()
+    }
+
     pub fn extract_user_function(&self) -> (UserFunction, Option<*mut ()>) {
         let addr = self.0 as usize;
         let flag = 1 << 63;
@@ -1,5 +1,6 @@
 pub(crate) mod channel;
 pub(crate) mod interface;
 pub(crate) mod map;
+pub(crate) mod runtime_error;
 pub(crate) mod slice;
 pub(crate) mod string;
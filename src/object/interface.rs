@@ -1,5 +1,6 @@
 use std::ptr;
 
+use crate::object::runtime_error::RuntimeError;
 use crate::object::string::StringObject;
 use crate::type_id::TypeId;
 use crate::FunctionObject;
@@ -9,13 +10,75 @@ use crate::ObjectPtr;
 #[repr(C)]
 pub(crate) struct InterfaceTableEntry {
     method_name: StringObject,
+    package_path: StringObject,
+    signature: StringObject,
     method: FunctionObject,
 }
 
+// Entries are immutable, the tables of the runtime are shared by all threads.
+unsafe impl Sync for InterfaceTableEntry {}
+
 impl InterfaceTableEntry {
+    // The methods defined by the runtime are all exported.
+    pub(crate) const fn new(
+        method_name: StringObject,
+        signature: StringObject,
+        method: FunctionObject,
+    ) -> Self {
+        Self {
+            method_name,
+            package_path: StringObject::from_static(b""),
+            signature,
+            method,
+        }
+    }
+
     pub(crate) fn method_name(&self) -> &StringObject {
         &self.method_name
     }
+
+    // Methods are the same only if their signatures are identical as well.
+    fn implements(&self, required: &InterfaceTableEntry) -> bool {
+        self.method_name == required.method_name
+            && self.package_path == required.package_path
+            && self.signature == required.signature
+    }
+}
+
+/// The methods of a concrete type in the order of the methods of an interface
+/// it implements, for calls through the interface to load them by slot. It is
+/// null for empty interfaces.
+#[derive(Debug, Clone, Copy)]
+#[repr(C)]
+pub(crate) struct Itab(*const FunctionObject);
+
+// Itabs are never modified nor freed once built.
+unsafe impl Send for Itab {}
+
+impl Itab {
+    pub(crate) fn null() -> Self {
+        Itab(ptr::null())
+    }
+
+    /// Builds the itab of the interface type for the concrete type, unless
+    /// the concrete type lacks one of the methods.
+    pub(crate) fn new(interface_type: &TypeId, type_id: &TypeId) -> Option<Self> {
+        let required = interface_type.interface_table();
+        if required.is_empty() {
+            return Some(Itab::null());
+        }
+        let table = type_id.interface_table();
+        let methods = required
+            .iter()
+            .map(|required| {
+                table
+                    .iter()
+                    .find(|entry| entry.implements(required))
+                    .map(|entry| entry.method.clone())
+            })
+            .collect::<Option<Vec<_>>>()?;
+        Some(Itab(Box::leak(methods.into_boxed_slice()).as_ptr()))
+    }
 }
 
 #[derive(Debug, Clone)]
@@ -23,17 +86,33 @@ impl InterfaceTableEntry {
 pub(crate) struct Interface {
     receiver: ObjectPtr,
     type_id: TypeId,
+    itab: Itab,
 }
 
 impl Interface {
     pub fn new(receiver: ObjectPtr, type_id: TypeId) -> Self {
-        Self { receiver, type_id }
+        let itab = Itab::null();
+        Self {
+            receiver,
+            type_id,
+            itab,
+        }
     }
 
     pub fn nil() -> Self {
-        let receiver = ObjectPtr(ptr::null_mut());
-        let type_id = TypeId::new_invalid();
-        Self { receiver, type_id }
+        Self::new(ObjectPtr(ptr::null_mut()), TypeId::new_invalid())
+    }
+
+    /// Returns the same value as seen through the interface of the itab.
+    pub fn with_itab(&self, itab: Itab) -> Self {
+        Self {
+            itab,
+            ..self.clone()
+        }
+    }
+
+    pub fn is_nil(&self) -> bool {
+        self.type_id == TypeId::new_invalid()
     }
 
     pub fn receiver(&self) -> &ObjectPtr {
@@ -44,17 +123,19 @@ impl Interface {
         &self.type_id
     }
 
-    pub fn search(&self, method_name: StringObject) -> Option<FunctionObject> {
+    pub fn implements(&self, required: &InterfaceTableEntry) -> bool {
         let table = self.type_id.interface_table();
-        for entry in table {
-            if entry.method_name == method_name {
-                return Some(entry.method.clone());
-            }
-        }
-        None
+        table.iter().any(|entry| entry.implements(required))
     }
 
     pub fn panic_print(&self) {
+        if let Some(error) = RuntimeError::from_interface(self) {
+            eprintln!(
+                "panic: {}",
+                String::from_utf8_lossy(error.message().as_bytes())
+            );
+            return;
+        }
         assert!(self.receiver.is_null());
         eprintln!("panic: nil");
     }
@@ -0,0 +1,110 @@
+use std::collections::hash_map::DefaultHasher;
+use std::hash::{Hash, Hasher};
+use std::mem;
+
+use crate::object::interface::{Interface, InterfaceTableEntry};
+use crate::object::string::StringObject;
+use crate::type_id::{TypeId, TypeInfo};
+use crate::FunctionObject;
+use crate::LightWeightThreadContext;
+use crate::ObjectAllocator;
+use crate::ObjectPtr;
+use crate::StackFrameCommon;
+
+/// The value of a panic raised by a check of the runtime, such as a bounds
+/// check. Go code sees it as a value implementing runtime.Error.
+#[repr(C)]
+pub(crate) struct RuntimeError {
+    message: *const StringObject,
+}
+
+impl RuntimeError {
+    /// Creates the interface holding the error with the message.
+    pub(crate) fn new_interface(message: &str, allocator: &mut dyn ObjectAllocator) -> Interface {
+        let mut builder = StringObject::builder(message.len(), allocator);
+        builder.append_bytes(message.as_bytes());
+        let message = builder.build();
+        let message_ptr = allocator.allocate(mem::size_of::<StringObject>(), |_ptr| {});
+        let message = unsafe {
+            (message_ptr as *mut StringObject).write(message);
+            message_ptr as *const StringObject
+        };
+
+        let receiver = allocator.allocate(mem::size_of::<RuntimeError>(), |_ptr| {});
+        unsafe {
+            (receiver as *mut RuntimeError).write(RuntimeError { message });
+        }
+        Interface::new(
+            ObjectPtr(receiver),
+            TypeId::from_type_info(&RUNTIME_ERROR_TYPE_INFO),
+        )
+    }
+
+    /// Returns the error held by the interface, if it is a runtime error.
+    pub(crate) fn from_interface(interface: &Interface) -> Option<&RuntimeError> {
+        let type_id = TypeId::from_type_info(&RUNTIME_ERROR_TYPE_INFO);
+        if interface.type_id() != &type_id || interface.receiver().is_null() {
+            return None;
+        }
+        Some(interface.receiver().as_ref())
+    }
+
+    pub(crate) fn message(&self) -> &StringObject {
+        unsafe { &*self.message }
+    }
+}
+
+// As the error is a single word, the method receives the pointer to the
+// message itself.
+#[repr(C)]
+struct StackFrameRuntimeErrorError<'a> {
+    common: StackFrameCommon,
+    result_ptr: &'a mut StringObject,
+    message: &'a StringObject,
+}
+
+unsafe extern "C" fn runtime_error_error(ctx: &mut LightWeightThreadContext) -> FunctionObject {
+    let frame = ctx.stack_frame_mut::<StackFrameRuntimeErrorError>();
+    *frame.result_ptr = frame.message.clone();
+    ctx.pop_frame()
+}
+
+unsafe extern "C" fn runtime_error_runtime_error(
+    ctx: &mut LightWeightThreadContext,
+) -> FunctionObject {
+    ctx.pop_frame()
+}
+
+extern "C" fn runtime_error_is_equal(lhs: ObjectPtr, rhs: ObjectPtr) -> bool {
+    lhs.as_ref::<RuntimeError>().message() == rhs.as_ref::<RuntimeError>().message()
+}
+
+extern "C" fn runtime_error_hash(obj: ObjectPtr) -> usize {
+    let mut hasher = DefaultHasher::new();
+    obj.as_ref::<RuntimeError>()
+        .message()
+        .as_bytes()
+        .hash(&mut hasher);
+    hasher.finish() as usize
+}
+
+static RUNTIME_ERROR_INTERFACE_TABLE: [InterfaceTableEntry; 2] = [
+    InterfaceTableEntry::new(
+        StringObject::from_static(b"Error"),
+        StringObject::from_static(b"func() string"),
+        FunctionObject::from_static_user_function(runtime_error_error),
+    ),
+    InterfaceTableEntry::new(
+        StringObject::from_static(b"RuntimeError"),
+        StringObject::from_static(b"func()"),
+        FunctionObject::from_static_user_function(runtime_error_runtime_error),
+    ),
+];
+
+static RUNTIME_ERROR_TYPE_INFO: TypeInfo = TypeInfo::new(
+    StringObject::from_static(b"runtime.Error"),
+    &RUNTIME_ERROR_INTERFACE_TABLE,
+    runtime_error_is_equal,
+    runtime_error_hash,
+    mem::size_of::<RuntimeError>(),
+);
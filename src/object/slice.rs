@@ -29,15 +29,14 @@ impl SliceObject {
         self.capacity
     }
 
+    /// Returns the bytes of the elements up to the length of the slice.
     pub(crate) fn as_bytes(&self, elem_size_in_bytes: usize) -> &[u8] {
         if self.ptr.is_null() {
             assert_eq!(self.capacity, 0);
             assert_eq!(self.size, 0);
             &[]
         } else {
-            unsafe {
-                slice::from_raw_parts(self.ptr as *const u8, self.capacity * elem_size_in_bytes)
-            }
+            unsafe { slice::from_raw_parts(self.ptr as *const u8, self.size * elem_size_in_bytes) }
         }
     }
 
@@ -1,24 +1,33 @@
-use std::ffi;
 use std::slice;
+use std::str;
 
 use crate::ObjectAllocator;
 
+/// A Go string, i.e. a pointer to its bytes and their count. The bytes are not
+/// terminated by NUL, and substrings share them with the original string.
 #[derive(Clone, Eq, Debug)]
 #[repr(C)]
-pub struct StringObject(*const u8);
+pub struct StringObject {
+    ptr: *const u8,
+    len: usize,
+}
 
 impl PartialEq for StringObject {
-    #[allow(clippy::unconditional_recursion)]
     fn eq(&self, other: &Self) -> bool {
-        let lhs = unsafe { ffi::CStr::from_ptr(self.0 as *const libc::c_char) };
-        let rhs = unsafe { ffi::CStr::from_ptr(other.0 as *const libc::c_char) };
-        lhs == rhs
+        self.as_bytes() == other.as_bytes()
     }
 }
 
 impl StringObject {
-    fn new(p: *const u8) -> Self {
-        Self(p)
+    fn new(ptr: *const u8, len: usize) -> Self {
+        Self { ptr, len }
+    }
+
+    pub(crate) const fn from_static(bytes: &'static [u8]) -> Self {
+        Self {
+            ptr: bytes.as_ptr(),
+            len: bytes.len(),
+        }
     }
 
     pub(crate) fn builder(
@@ -29,15 +38,28 @@ impl StringObject {
     }
 
     pub(crate) fn len_in_bytes(&self) -> usize {
-        unsafe { libc::strlen(self.0 as *const libc::c_char) }
+        self.len
     }
 
     pub(crate) fn as_bytes(&self) -> &[u8] {
-        unsafe { ffi::CStr::from_ptr(self.0 as *const libc::c_char).to_bytes() }
+        if self.len == 0 {
+            return &[];
+        }
+        unsafe { slice::from_raw_parts(self.ptr, self.len) }
     }
+}
 
-    pub(crate) fn to_str(&self) -> Result<&str, std::str::Utf8Error> {
-        unsafe { ffi::CStr::from_ptr(self.0 as *const libc::c_char).to_str() }
+/// Decodes the rune at the head of bytes like Go does, returning it with its
+/// width in bytes. An invalid encoding yields U+FFFD with width 1.
+pub(crate) fn decode_rune(bytes: &[u8]) -> (char, usize) {
+    let len = bytes.len().min(4);
+    let valid = match str::from_utf8(&bytes[..len]) {
+        Ok(s) => s,
+        Err(error) => unsafe { str::from_utf8_unchecked(&bytes[..error.valid_up_to()]) },
+    };
+    match valid.chars().next() {
+        Some(ch) => (ch, ch.len_utf8()),
+        None => (char::REPLACEMENT_CHARACTER, 1),
     }
 }
 
@@ -49,7 +71,7 @@ pub(crate) struct StringObjectBuilder {
 
 impl StringObjectBuilder {
     fn new(len_in_bytes: usize, allocator: &mut dyn ObjectAllocator) -> Self {
-        let ptr = allocator.allocate(len_in_bytes + 1, |_| {}) as *mut u8;
+        let ptr = allocator.allocate(len_in_bytes, |_| {}) as *mut u8;
         Self {
             ptr,
             len_in_bytes,
@@ -58,7 +80,10 @@ impl StringObjectBuilder {
     }
 
     fn as_mut_slice(&mut self) -> &mut [u8] {
-        unsafe { slice::from_raw_parts_mut(self.ptr, self.len_in_bytes + 1) }
+        if self.len_in_bytes == 0 {
+            return &mut [];
+        }
+        unsafe { slice::from_raw_parts_mut(self.ptr, self.len_in_bytes) }
     }
 
     pub fn append_bytes(&mut self, src: &[u8]) {
@@ -84,12 +109,23 @@ impl StringObjectBuilder {
         assert!(self.cursor <= self.len_in_bytes);
     }
 
-    pub fn build(mut self) -> StringObject {
+    pub fn build(self) -> StringObject {
         assert!(self.cursor == self.len_in_bytes);
+        StringObject::new(self.ptr, self.len_in_bytes)
+    }
+}
 
-        let index = self.cursor;
-        let bytes = self.as_mut_slice();
-        bytes[index] = 0;
-        StringObject::new(self.ptr)
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_decode_rune() {
+        assert_eq!(decode_rune("aé".as_bytes()), ('a', 1));
+        assert_eq!(decode_rune("é".as_bytes()), ('é', 2));
+        assert_eq!(decode_rune("世界".as_bytes()), ('世', 3));
+        assert_eq!(decode_rune(b"\xffa"), (char::REPLACEMENT_CHARACTER, 1));
+        assert_eq!(decode_rune(b"\xe4\xb8"), (char::REPLACEMENT_CHARACTER, 1));
+        assert_eq!(decode_rune(b"\x00"), ('\0', 1));
     }
 }
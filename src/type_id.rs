@@ -5,16 +5,39 @@ use crate::object::interface::InterfaceTableEntry;
 use crate::object::string::StringObject;
 
 #[repr(C)]
-struct TypeInfo {
+pub(crate) struct TypeInfo {
     name: StringObject,
     num_methods: usize,
     interface_table: *const InterfaceTableEntry,
-    is_equal: extern "C" fn(ObjectPtr, ObjectPtr) -> bool,
-    hash: extern "C" fn(ObjectPtr) -> usize,
+    // None for types which are not comparable
+    is_equal: Option<extern "C" fn(ObjectPtr, ObjectPtr) -> bool>,
+    hash: Option<extern "C" fn(ObjectPtr) -> usize>,
     size: usize,
 }
 
-#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+// Type infos are immutable, the ones of the runtime are shared by all threads.
+unsafe impl Sync for TypeInfo {}
+
+impl TypeInfo {
+    pub(crate) const fn new(
+        name: StringObject,
+        interface_table: &'static [InterfaceTableEntry],
+        is_equal: extern "C" fn(ObjectPtr, ObjectPtr) -> bool,
+        hash: extern "C" fn(ObjectPtr) -> usize,
+        size: usize,
+    ) -> Self {
+        Self {
+            name,
+            num_methods: interface_table.len(),
+            interface_table: interface_table.as_ptr(),
+            is_equal: Some(is_equal),
+            hash: Some(hash),
+            size,
+        }
+    }
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
 #[repr(C)]
 pub(crate) struct TypeId(usize);
 
@@ -23,6 +46,10 @@ impl TypeId {
         TypeId(0)
     }
 
+    pub(crate) fn from_type_info(type_info: &'static TypeInfo) -> Self {
+        TypeId(type_info as *const TypeInfo as usize)
+    }
+
     fn type_info(&self) -> &TypeInfo {
         unsafe { &*(self.0 as *const TypeInfo) }
     }
@@ -32,6 +59,11 @@ impl TypeId {
         unsafe { slice::from_raw_parts(type_info.interface_table, type_info.num_methods) }
     }
 
+    pub(crate) fn name(&self) -> &StringObject {
+        let type_info = self.type_info();
+        &type_info.name
+    }
+
     pub fn size(&self) -> usize {
         let type_info = self.type_info();
         type_info.size
@@ -39,11 +71,11 @@ impl TypeId {
 
     pub fn is_equal_func(&self) -> extern "C" fn(ObjectPtr, ObjectPtr) -> bool {
         let type_info = self.type_info();
-        type_info.is_equal
+        type_info.is_equal.unwrap()
     }
 
     pub fn hash_func(&self) -> extern "C" fn(ObjectPtr) -> usize {
         let type_info = self.type_info();
-        type_info.hash
+        type_info.hash.unwrap()
     }
 }
@@ -200,6 +200,70 @@ func Test17() int {
 	return 17
 }
 
+func Test18() int {
+	s := "ab"
+	a := [2]string{s[:1], s[1:] + "c"}
+	b := [2]string{"a", "bc"}
+	if a != b || !(a == b) {
+		return 0
+	}
+	b[1] = "bd"
+	if a == b {
+		return 1
+	}
+	m := map[[2]string]int{a: 1}
+	if m[[2]string{"a", "bc"}] != 1 {
+		return 2
+	}
+	return 18
+}
+
+func Test19() int {
+	zero := 0.0
+	nan := zero / zero
+	a := [2]float64{zero, 1}
+	b := [2]float64{-zero, 1}
+	if a != b {
+		return 0
+	}
+	c := [1]float64{nan}
+	if c == c {
+		return 1
+	}
+	var d [0]int
+	if d != [0]int{} {
+		return 2
+	}
+	return 19
+}
+
+type padded struct {
+	a int8
+	b int64
+}
+
+func Test20() int {
+	a := [2]padded{{1, 2}, {3, 4}}
+	b := [2]padded{}
+	b[0].a, b[0].b = 1, 2
+	b[1].a, b[1].b = 3, 4
+	if a != b {
+		return 0
+	}
+	i, j := 1, 1
+	p := [2]*int{&i, &j}
+	q := [2]*int{&i, &j}
+	if p != q || p == [2]*int{&j, &i} {
+		return 1
+	}
+	x := [2]interface{}{1, "a"}
+	y := [2]interface{}{1, "a"}
+	if x != y || x == [2]interface{}{1, "b"} {
+		return 2
+	}
+	return 20
+}
+
 func main() {
 	runTest := func(testName string, test func() int) {
 		println(testName+":", test())
@@ -221,4 +285,7 @@ func main() {
 	runTest("Test15", Test15)
 	runTest("Test16", Test16)
 	runTest("Test17", Test17)
+	runTest("Test18", Test18)
+	runTest("Test19", Test19)
+	runTest("Test20", Test20)
 }
@@ -0,0 +1,63 @@
+package main
+
+func catch(message *string, f func()) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			*message = "no panic"
+			return
+		}
+		*message = v.(error).Error()
+	}()
+	f()
+}
+
+func runCase(name string, f func()) {
+	var message string
+	catch(&message, f)
+	println(name+":", message)
+}
+
+func TestIndex() {
+	a := [3]int{1, 2, 3}
+	s := []int{1, 2, 3, 4}
+	str := "abc"
+	i := 5
+	n := -1
+	var u uint8 = 200
+	runCase("array", func() { println(a[i]) })
+	runCase("array negative", func() { println(a[n]) })
+	runCase("slice", func() { s[i] = 0 })
+	runCase("slice unsigned", func() { s[u] = 0 })
+	runCase("slice negative", func() { println(s[n]) })
+	runCase("pointer to array", func() { p := &a; p[i] = 0 })
+	runCase("string", func() { println(str[i]) })
+	runCase("in range", func() { println(s[3], str[2], a[2]) })
+}
+
+func TestSlice() {
+	a := [3]int{1, 2, 3}
+	s := make([]int, 2, 4)
+	str := "abc"
+	i, j, k := 3, 2, 5
+	n := -1
+	runCase("slice high", func() { println(len(s[:k])) })
+	runCase("slice high length", func() { println(len(s[:3])) })
+	runCase("slice low", func() { println(len(s[i:])) })
+	runCase("slice low high", func() { println(len(s[i:j])) })
+	runCase("slice negative", func() { println(len(s[n:])) })
+	runCase("array high", func() { println(len(a[:k])) })
+	runCase("string high", func() { println(str[:k]) })
+	runCase("string low", func() { println(str[k:]) })
+	runCase("string low high", func() { println(str[i:j]) })
+	runCase("slice3 max", func() { println(len(s[:2:k])) })
+	runCase("slice3 high", func() { println(len(s[:i:j])) })
+	runCase("slice3 low", func() { println(len(s[i:j:4])) })
+	runCase("array slice3 max", func() { println(len(a[0:1:k])) })
+	runCase("in range", func() { t := s[1:3:4]; println(len(t), cap(t), len(str[1:]), len(a[1:2])) })
+}
+
+func main() {
+	TestIndex()
+	TestSlice()
+}
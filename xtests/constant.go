@@ -0,0 +1,47 @@
+package main
+
+import "math"
+
+const third = 1.0 / 3
+
+func TestFloat() {
+	println(math.Float64bits(math.Pi), math.Float64bits(math.E), math.Float64bits(third))
+	println(math.Float64bits(math.MaxFloat64), math.Float64bits(math.SmallestNonzeroFloat64))
+	println(math.Float32bits(math.Pi), math.Float32bits(math.MaxFloat32), math.Float32bits(math.SmallestNonzeroFloat32))
+	println(math.Float64bits(0x1p-1074), math.Float64bits(-0.1), math.Float32bits(0.1))
+	x := 2147483648.0
+	println(int64(x), x == 2147483648, math.Float64bits(1e23))
+}
+
+func TestInteger() {
+	var i64 int64 = math.MinInt64
+	var i int = math.MinInt64
+	var i32 int32 = math.MinInt32
+	var i8 int8 = math.MinInt8
+	var u64 uint64 = math.MaxUint64
+	var u uint = math.MaxUint
+	var up uintptr = 1 << 63
+	println(i64, i, i32, i8, u64, u, up)
+	println(i64 == math.MinInt64, i64+1, -(i64 + 1), math.MaxInt64, math.MaxUint32)
+}
+
+func TestComplex() {
+	c := complex(math.Pi, -math.E)
+	var c64 complex64 = complex(math.Pi, 1.0/3)
+	println(math.Float64bits(real(c)), math.Float64bits(imag(c)))
+	println(math.Float32bits(real(c64)), math.Float32bits(imag(c64)))
+	println(c == complex(math.Pi, -math.E))
+}
+
+func TestNonFinite() {
+	inf := math.Inf(1)
+	nan := math.NaN()
+	println(inf > math.MaxFloat64, -inf < -math.MaxFloat64, nan == nan, nan != nan)
+}
+
+func main() {
+	TestFloat()
+	TestInteger()
+	TestComplex()
+	TestNonFinite()
+}
@@ -0,0 +1,72 @@
+package main
+
+import "unsafe"
+
+func convert64(f float64) {
+	println(int8(f), int16(f), int32(f), int64(f), int(f))
+	println(" ", uint8(f), uint16(f), uint32(f), uint64(f), uint(f), uintptr(f))
+}
+
+func convert32(f float32) {
+	println(int8(f), int16(f), int32(f), int64(f), int(f))
+	println(" ", uint8(f), uint16(f), uint32(f), uint64(f), uint(f), uintptr(f))
+}
+
+// The values are computed at run time, as the conversion of constants is done
+// by the type checker.
+func value(i int64, fraction float64) float64 {
+	return float64(i) + fraction
+}
+
+func TestFloatToInteger() {
+	zero := 0.0
+	p31 := value(2147483647, 1)
+	p63 := value(9223372036854775807, 0)
+	var u64 uint64 = 18446744073709551615
+	values := []float64{
+		0, -0.9, 0.9, -1, 127.5, 128, 255.9, 256, -129, 300.5, -300.5, 65535.9, 65536, -32769,
+		value(2147483647, 0.9), p31, value(-2147483648, -0.9), value(-2147483648, -1),
+		value(4294967295, 0.5), value(4294967295, 1), 1e10, -1e10,
+		p63, -p63, value(-9223372036854775807, -2049), float64(u64), 2 * p63, 1.8e19, 1e30, -1e30,
+		zero / zero, 1 / zero, -1 / zero,
+	}
+	for _, v := range values {
+		convert64(v)
+		convert32(float32(v))
+	}
+}
+
+func TestIntegerToFloat() {
+	var u64 uint64 = 18446744073709551615
+	var u64b uint64 = 9223372036854777857
+	var i64 int64 = -9223372036854775807
+	var u32 uint32 = 4294967295
+	var i8 int8 = -128
+	println(float64(u64), float32(u64), float64(u64b), float32(u64b))
+	println(float64(i64), float32(i64), float64(u32), float32(u32), float64(i8))
+}
+
+func TestFloat() {
+	big := 1e300
+	small := 1e-300
+	f := 3.14159265358979
+	println(float64(float32(big)) > big, float64(float32(-big)) < -big, float32(small), float32(f), float64(float32(f)))
+	c := complex(f, -1e10)
+	c64 := complex64(c)
+	println(c64, complex128(c64), real(c64), imag(c64))
+}
+
+func TestPointer() {
+	x := 42
+	p := unsafe.Pointer(&x)
+	u := uintptr(p)
+	q := (*int)(unsafe.Pointer(u))
+	println(*q, u == uintptr(unsafe.Pointer(&x)))
+}
+
+func main() {
+	TestFloatToInteger()
+	TestIntegerToFloat()
+	TestFloat()
+	TestPointer()
+}
@@ -255,6 +255,60 @@ func Test11() int {
 	return 11
 }
 
+type Small struct {
+	a int8
+	b int16
+	c bool
+}
+
+func test12_f(p *int, b bool, i8 int8, f32 float32, i32 int32, s Small, n int) {
+	if b && i8 == -3 && f32 == 1.5 && i32 == -7 && s.a == 1 && s.b == 2 && s.c && n == 9 {
+		*p = 12
+	}
+}
+
+func Test12() int {
+	a := 0
+	func() {
+		defer test12_f(&a, true, -3, 1.5, -7, Small{1, 2, true}, 9)
+	}()
+	if a != 12 {
+		return 0
+	}
+	return 12
+}
+
+type I13 interface {
+	set(b bool, i8 int8, s Small, f32 float32)
+}
+
+type S13 struct {
+	n int
+}
+
+func (s *S13) set(b bool, i8 int8, t Small, f32 float32) {
+	if b && i8 == 5 && t.a == 3 && t.b == 4 && !t.c && f32 == 2.5 {
+		s.n = 13
+	}
+}
+
+func Test13() int {
+	s := S13{}
+	var i I13 = &s
+	func() {
+		defer i.set(true, 5, Small{3, 4, false}, 2.5)
+	}()
+	if s.n != 13 {
+		return 0
+	}
+	s.n = 0
+	i.set(true, 5, Small{3, 4, false}, 2.5)
+	if s.n != 13 {
+		return 1
+	}
+	return 13
+}
+
 func main() {
 	runTest := func(testName string, test func() int) {
 		println(testName+":", test())
@@ -270,4 +324,6 @@ func main() {
 	runTest("Test9", Test9)
 	runTest("Test10", Test10)
 	runTest("Test11", Test11)
+	runTest("Test12", Test12)
+	runTest("Test13", Test13)
 }
@@ -219,6 +219,47 @@ func Test15() int {
 	return v
 }
 
+type Small struct {
+	a int8
+	b int16
+	c bool
+}
+
+func test16_f(c chan int, b bool, i8 int8, f32 float32, s Small, n int) {
+	if b && i8 == -3 && f32 == 1.5 && s.a == 1 && s.b == 2 && s.c && n == 9 {
+		c <- 16
+		return
+	}
+	c <- 0
+}
+
+type I16 interface {
+	send(b bool, s Small, i8 int8)
+}
+
+type S16 struct {
+	c chan int
+}
+
+func (s *S16) send(b bool, t Small, i8 int8) {
+	if b && t.a == 3 && t.b == 4 && !t.c && i8 == 5 {
+		s.c <- 16
+		return
+	}
+	s.c <- 1
+}
+
+func Test16() int {
+	c := make(chan int)
+	go test16_f(c, true, -3, 1.5, Small{1, 2, true}, 9)
+	if n := <-c; n != 16 {
+		return n
+	}
+	var i I16 = &S16{c}
+	go i.send(true, Small{3, 4, false}, 5)
+	return <-c
+}
+
 func main() {
 	runTest := func(testName string, test func() int) {
 		println(testName+":", test())
@@ -238,4 +279,5 @@ func main() {
 	runTest("Test13", Test13)
 	runTest("Test14", Test14)
 	runTest("Test15", Test15)
+	runTest("Test16", Test16)
 }
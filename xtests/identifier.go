@@ -0,0 +1,32 @@
+package main
+
+type Größe struct {
+	wert int
+	höhe int
+}
+
+func (g *Größe) Fläche() int {
+	return g.wert * g.höhe
+}
+
+type Flächig interface {
+	Fläche() int
+}
+
+var zähler int
+
+func héllo(名前 string) string {
+	zähler++
+	return "héllo, " + 名前
+}
+
+func main() {
+	println(héllo("世界"))
+	g := &Größe{wert: 3, höhe: 4}
+	var f Flächig = g
+	println(g.Fläche(), f.Fläche())
+	π := func(n int) int { return n * 2 }
+	println(π(zähler))
+	_, ok := f.(interface{ Fläche() int })
+	println(ok)
+}
@@ -0,0 +1,92 @@
+package main
+
+func catch(message *string, f func()) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			*message = "no panic"
+			return
+		}
+		*message = v.(error).Error()
+	}()
+	f()
+}
+
+func runCase(name string, f func()) {
+	var message string
+	catch(&message, f)
+	println(name+":", message)
+}
+
+func fnv(data []byte) uint32 {
+	h := uint32(2166136261)
+	for _, b := range data {
+		h ^= uint32(b)
+		h *= 16777619
+	}
+	return h
+}
+
+func TestWrapAround() {
+	var i8 int8 = 127
+	var i16 int16 = 32767
+	var i32 int32 = 2147483647
+	var i64 int64 = 9223372036854775807
+	var i int = -9223372036854775808
+	var u8 uint8 = 255
+	var u16 uint16 = 65535
+	var u32 uint32 = 4294967295
+	var u64 uint64 = 18446744073709551615
+	println(i8+1, i8*2, -i8-2, -(i8 + 1))
+	println(i16+1, i16*i16, i16-(-2))
+	println(i32+1, i32*i32, -i32-2)
+	println(i64+1, i64*3, -(i64 + 1))
+	println(i-1, -i, i*-1)
+	println(u8+1, u8*u8, u8-u8-1)
+	println(u16+1, u16*u16, -u16)
+	println(u32+1, u32*u32, 0-u32)
+	println(u64+1, u64*u64, -u64)
+	println(fnv([]byte("hello, world")))
+
+	x := int64(1)
+	for n := 0; n < 70; n++ {
+		x = x*6364136223846793005 + 1442695040888963407
+	}
+	println(x)
+}
+
+func TestDivision() {
+	var minInt8 int8 = -128
+	var minInt int = -9223372036854775808
+	var minusOne8 int8 = -1
+	minusOne := -1
+	println(minInt8/minusOne8, minInt8%minusOne8)
+	println(minInt/minusOne, minInt%minusOne)
+	println(-7/2, -7%2, 7/-2, 7%-2)
+	zero := 0
+	var uzero uint8
+	runCase("int", func() { println(1 / zero) })
+	runCase("int remainder", func() { println(1 % zero) })
+	runCase("uint8", func() { println(uint8(1) / uzero) })
+	runCase("float", func() { f := 0.0; println(1/f > 1e308) })
+}
+
+func TestShift() {
+	x := 1
+	var u uint64 = 1
+	var i8 int8 = -128
+	n := 70
+	s := 3
+	println(x<<n, u<<n, x<<63, u<<63, i8>>n, i8>>s, i8<<1)
+	println(-1>>s, -8>>s, uint8(200)>>s)
+	m := -1
+	runCase("shift left", func() { println(x << m) })
+	runCase("shift right", func() { println(x >> m) })
+	runCase("unsigned count", func() { println(x << uint(n)) })
+}
+
+func main() {
+	TestWrapAround()
+	TestDivision()
+	TestShift()
+}
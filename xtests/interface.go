@@ -697,6 +697,81 @@ func Test30() int {
 	return 30
 }
 
+type I5 interface {
+	a() int
+	z() int
+}
+
+type I6 interface {
+	I5
+	m() int
+}
+
+type S5 struct {
+	n int
+}
+
+func (s *S5) a() int { return s.n + 1 }
+func (s *S5) m() int { return s.n + 2 }
+func (s *S5) z() int { return s.n + 3 }
+
+func Test31() int {
+	var i6 I6 = &S5{n: 10}
+	if i6.a() != 11 || i6.m() != 12 || i6.z() != 13 {
+		return 0
+	}
+	var i5 I5 = i6
+	if i5.a() != 11 || i5.z() != 13 {
+		return 1
+	}
+	var e interface{} = i6
+	if e.(I5).z() != 13 || e.(I6).m() != 12 {
+		return 2
+	}
+	return 31
+}
+
+func Test32() int {
+	var i6 I6
+	var i5 I5 = i6
+	if i5 != nil {
+		return 0
+	}
+	var e interface{} = i5
+	if e != nil {
+		return 1
+	}
+	return 32
+}
+
+func Test33() int {
+	s := S0{n: 42}
+	var i I4 = &s
+	f := func() {
+		defer i.h(44)
+		i.h(43)
+	}
+	f()
+	if s.n != 44 {
+		return 0
+	}
+	c := S6{c: make(chan int)}
+	i = &c
+	go i.h(45)
+	if <-c.c != 45 {
+		return 1
+	}
+	return 33
+}
+
+type S6 struct {
+	c chan int
+}
+
+func (s *S6) h(n int) {
+	s.c <- n
+}
+
 func main() {
 	runTest := func(testName string, test func() int) {
 		println(testName+":", test())
@@ -731,4 +806,7 @@ func main() {
 	runTest("Test28", Test28)
 	runTest("Test29", Test29)
 	runTest("Test30", Test30)
+	runTest("Test31", Test31)
+	runTest("Test32", Test32)
+	runTest("Test33", Test33)
 }
@@ -0,0 +1,84 @@
+package main
+
+type T struct {
+	n int
+	v interface{}
+}
+
+type Name string
+
+type F func()
+
+func catch(f func()) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			println("no panic")
+			return
+		}
+		println(v.(error).Error())
+	}()
+	f()
+}
+
+func TestDynamicType() {
+	var x, y interface{} = int32(1), uint32(1)
+	println(x == y, x == interface{}(int32(1)), x != y)
+	var s, n interface{} = "a", Name("a")
+	println(s == n, n == interface{}(Name("a")))
+	var p *int
+	var q *string
+	x, y = p, q
+	println(x == y, x == interface{}(p), x != nil)
+	var e interface{}
+	println(e == nil, e == x)
+}
+
+func TestStruct() {
+	a := T{1, "a"}
+	b := T{1, "a"}
+	c := T{1, 1}
+	println(a == b, a == c, a != c)
+}
+
+func TestUncomparable() {
+	catch(func() {
+		var a, b interface{} = []int{1}, []int{1}
+		println(a == b)
+	})
+	catch(func() {
+		println(T{1, map[string]int{}} == T{1, map[string]int{}})
+	})
+	catch(func() {
+		var a, b interface{} = F(func() {}), 1
+		println(a == b)
+	})
+	catch(func() {
+		var a interface{} = struct{ s []string }{}
+		println(a == a)
+	})
+}
+
+func TestUnhashable() {
+	m := make(map[interface{}]int)
+	catch(func() {
+		m[[]int{}] = 1
+	})
+	catch(func() {
+		println(m[F(func() {})])
+	})
+	catch(func() {
+		delete(m, T{0, map[int]int{}})
+	})
+	catch(func() {
+		m[T{0, 1}] = 1
+		println(m[T{0, 1}], len(m))
+	})
+}
+
+func main() {
+	TestDynamicType()
+	TestStruct()
+	TestUncomparable()
+	TestUnhashable()
+}
@@ -236,6 +236,112 @@ func Test12() int {
 	return 12
 }
 
+func Test13() int {
+	m := make(map[string]int)
+	words := "alpha beta gamma delta alpha beta alpha"
+	start := 0
+	for i := 0; i <= len(words); i++ {
+		if i == len(words) || words[i] == ' ' {
+			m[words[start:i]]++
+			start = i + 1
+		}
+	}
+	if len(m) != 4 || m["alpha"] != 3 || m["beta"] != 2 || m["gamma"] != 1 || m["delta"] != 1 {
+		return 0
+	}
+	if _, ok := m["alp"]; ok {
+		return 1
+	}
+	for i := 0; i < 100; i++ {
+		m[string(rune('A'+i%26))+string(rune('a'+i/26))] = i
+	}
+	if len(m) != 104 || m["Cb"] != 28 {
+		return 2
+	}
+	delete(m, "alpha")
+	if _, ok := m["alpha"]; ok || len(m) != 103 {
+		return 3
+	}
+	return 13
+}
+
+func Test14() int {
+	m := make(map[float64]int)
+	zero := 0.0
+	m[zero] = 1
+	m[-zero] = 2
+	if len(m) != 1 || m[0] != 2 {
+		return 0
+	}
+	nan := zero / zero
+	m[nan] = 3
+	m[nan] = 4
+	if len(m) != 3 {
+		return 1
+	}
+	if _, ok := m[nan]; ok {
+		return 2
+	}
+	c := make(map[complex128]int)
+	c[complex(1, zero)] = 1
+	c[complex(1, -zero)]++
+	if len(c) != 1 || c[1] != 2 {
+		return 3
+	}
+	f := map[float32]bool{1.5: true}
+	if !f[1.5] || f[2.5] {
+		return 4
+	}
+	return 14
+}
+
+type key15 struct {
+	name string
+	n    int
+}
+
+func Test15() int {
+	m := make(map[key15]int)
+	m[key15{"a", 1}] = 1
+	m[key15{"a", 2}] = 2
+	m[key15{"a" + "", 1}] += 10
+	if len(m) != 2 || m[key15{"a", 1}] != 11 {
+		return 0
+	}
+	a := make(map[[2]int]int)
+	a[[2]int{1, 2}] = 1
+	a[[2]int{2, 1}] = 2
+	if len(a) != 2 || a[[2]int{1, 2}] != 1 {
+		return 1
+	}
+	x, y := 1, 1
+	p := map[*int]int{&x: 1, &y: 2}
+	if len(p) != 2 || p[&x] != 1 {
+		return 2
+	}
+	ch := make(chan int)
+	cm := map[chan int]int{ch: 1}
+	if cm[ch] != 1 || cm[make(chan int)] != 0 {
+		return 3
+	}
+	return 15
+}
+
+func Test16() int {
+	m := make(map[interface{}]int)
+	m[1] = 1
+	m["one"] = 2
+	m[key15{"a", 1}] = 3
+	m[1]++
+	if len(m) != 3 || m[1] != 2 || m["one"] != 2 || m[key15{"a", 1}] != 3 {
+		return 0
+	}
+	if _, ok := m["two"]; ok {
+		return 1
+	}
+	return 16
+}
+
 func main() {
 	runTest := func(testName string, test func() int) {
 		println(testName+":", test())
@@ -252,4 +358,8 @@ func main() {
 	runTest("Test10", Test10)
 	runTest("Test11", Test11)
 	runTest("Test12", Test12)
+	runTest("Test13", Test13)
+	runTest("Test14", Test14)
+	runTest("Test15", Test15)
+	runTest("Test16", Test16)
 }
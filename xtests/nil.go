@@ -0,0 +1,80 @@
+package main
+
+type T struct {
+	x int
+	y [3]int
+}
+
+type I interface {
+	M() int
+}
+
+func (t *T) M() int {
+	return t.x
+}
+
+func catch(message *string, f func()) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			*message = "no panic"
+			return
+		}
+		*message = v.(error).Error()
+	}()
+	f()
+}
+
+func runCase(name string, f func()) {
+	var message string
+	catch(&message, f)
+	println(name+":", message)
+}
+
+func deferNilFunc(x *int) {
+	var f func()
+	defer f()
+	*x = 1
+}
+
+func deferNilInterface() {
+	var i I
+	defer i.M()
+}
+
+func TestPointer() {
+	var p *int
+	var t *T
+	var a *[3]int
+	runCase("load", func() { println(*p) })
+	runCase("store", func() { *p = 1 })
+	runCase("field load", func() { println(t.x) })
+	runCase("field store", func() { t.x = 1 })
+	runCase("field array", func() { t.y[1] = 1 })
+	runCase("array index", func() { println(a[1]) })
+	runCase("array slice", func() { println(len(a[:])) })
+	runCase("method", func() { println(t.M()) })
+	runCase("non-nil", func() { t = &T{x: 2}; p = &t.x; *p = 3; println(t.M()) })
+}
+
+func TestFunction() {
+	var f func() int
+	runCase("call", func() { println(f()) })
+	x := 0
+	runCase("defer", func() { deferNilFunc(&x) })
+	println("deferred:", x)
+}
+
+func TestInterface() {
+	var i I
+	runCase("call", func() { println(i.M()) })
+	runCase("defer", deferNilInterface)
+	runCase("go", func() { go i.M() })
+	runCase("nil pointer", func() { var t *T; i = t; println(i.M()) })
+}
+
+func main() {
+	TestPointer()
+	TestFunction()
+	TestInterface()
+}
@@ -0,0 +1,10 @@
+package main
+
+type T struct {
+	x int
+}
+
+func main() {
+	var t *T
+	println(t.x)
+}
@@ -96,6 +96,37 @@ func Test11() int {
 	return 11
 }
 
+type Name string
+type Num int
+type Real float64
+
+func Test12() int {
+	var p *int
+	var m map[int]int
+	var c chan int
+	var f func()
+	var s []int
+	var e interface{}
+	var err error
+	println(p, m, c, f, s, e, err)
+	return 12
+}
+
+func Test13() int {
+	println(Name("abc"), Num(-3), Real(1.5))
+	var n float32 = 2.5
+	println(n)
+	return 13
+}
+
+func Test14() int {
+	zero := 0.0
+	println(1/zero, -1/zero, zero/zero, -zero)
+	print(1/zero, " ", zero/zero, "\n")
+	println(complex(1/zero, zero/zero))
+	return 14
+}
+
 func main() {
 	runTest := func(testName string, test func() int) {
 		println(testName+":", test())
@@ -111,4 +142,7 @@ func main() {
 	runTest("Test9", Test9)
 	runTest("Test10", Test10)
 	runTest("Test11", Test11)
+	runTest("Test12", Test12)
+	runTest("Test13", Test13)
+	runTest("Test14", Test14)
 }
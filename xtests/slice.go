@@ -626,6 +626,38 @@ func Test34() int {
 	return 34
 }
 
+func Test35() int {
+	s := []int{1, 2, 3, 4}
+	t := []int{0}
+	t = append(t, s[1:2]...)
+	if len(t) != 2 || t[1] != 2 {
+		return 0
+	}
+	u := make([]int, 4)
+	if copy(u, s[:1]) != 1 || u[1] != 0 {
+		return 1
+	}
+	b := []byte("hello")
+	if string(b[:2]) != "he" {
+		return 2
+	}
+	return 35
+}
+
+func Test36() int {
+	s := []int{}
+	for i := 0; i < 5; i++ {
+		s = append(s, i)
+		if len(s) != i+1 {
+			return i
+		}
+	}
+	if s[4] != 4 {
+		return 5
+	}
+	return 36
+}
+
 func main() {
 	runTest := func(testName string, test func() int) {
 		println(testName+":", test())
@@ -664,4 +696,6 @@ func main() {
 	runTest("Test32", Test32)
 	runTest("Test33", Test33)
 	runTest("Test34", Test34)
+	runTest("Test35", Test35)
+	runTest("Test36", Test36)
 }
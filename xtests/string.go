@@ -364,6 +364,109 @@ func Test26() int {
 	return 26
 }
 
+func Test27() int {
+	s := "a\x00b\x00"
+	if len(s) != 4 {
+		return 0
+	}
+	if s[1] != 0 || s[2] != 'b' {
+		return 1
+	}
+	t := s + s
+	if len(t) != 8 || t[6] != 'b' {
+		return 2
+	}
+	b := []byte(t)
+	if len(b) != 8 || string(b) != t {
+		return 3
+	}
+	return 27
+}
+
+func Test28() int {
+	s := "hello, world"
+	t := s[7:]
+	if len(t) != 5 || t != "world" {
+		return 0
+	}
+	u := t[1:3]
+	if len(u) != 2 || u != "or" {
+		return 1
+	}
+	if s[:0] != "" || s[12:] != "" {
+		return 2
+	}
+	if s[:5]+s[5:] != s {
+		return 3
+	}
+	return 28
+}
+
+func Test29() int {
+	s := "x\x00y"
+	m := map[int]string{0: s}
+	if m[0] != s || m[0] == "x" {
+		return 0
+	}
+	if "x\x00" >= s || "x" >= "x\x00" {
+		return 1
+	}
+	println(s[1:2] == "\x00", len(s[:1]))
+	return 29
+}
+
+func Test30() int {
+	s := "aé世b"
+	indices := []int{}
+	runes := []rune{}
+	for i, c := range s {
+		indices = append(indices, i)
+		runes = append(runes, c)
+	}
+	if len(indices) != 4 || indices[1] != 1 || indices[2] != 3 || indices[3] != 6 {
+		return 0
+	}
+	if runes[1] != 'é' || runes[2] != '世' || runes[3] != 'b' {
+		return 1
+	}
+	return 30
+}
+
+func Test31() int {
+	rs := []rune("aé世b")
+	if len(rs) != 4 || cap(rs) < 4 {
+		return 0
+	}
+	if rs[1] != 'é' || rs[2] != '世' || rs[3] != 'b' {
+		return 1
+	}
+	if string(rs) != "aé世b" {
+		return 2
+	}
+	return 31
+}
+
+func Test32() int {
+	s := "a\xffé\xe4\xb8b"
+	indices := []int{}
+	runes := []rune{}
+	for i, c := range s {
+		indices = append(indices, i)
+		runes = append(runes, c)
+	}
+	if len(indices) != 6 || indices[1] != 1 || indices[2] != 2 || indices[3] != 4 || indices[4] != 5 || indices[5] != 6 {
+		return 0
+	}
+	if runes[1] != 0xfffd || runes[2] != 'é' || runes[3] != 0xfffd || runes[4] != 0xfffd || runes[5] != 'b' {
+		return 1
+	}
+	rs := []rune(s)
+	if len(rs) != 6 || rs[1] != 0xfffd || rs[4] != 0xfffd {
+		return 2
+	}
+	return 32
+}
+
 func main() {
 	runTest := func(testName string, test func() int) {
 		println(testName+":", test())
@@ -396,4 +499,10 @@ func main() {
 	runTest("Test24", Test24)
 	runTest("Test25", Test25)
 	runTest("Test26", Test26)
+	runTest("Test27", Test27)
+	runTest("Test28", Test28)
+	runTest("Test29", Test29)
+	runTest("Test30", Test30)
+	runTest("Test31", Test31)
+	runTest("Test32", Test32)
 }
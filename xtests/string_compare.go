@@ -0,0 +1,49 @@
+package main
+
+func sortStrings(s []string) {
+	for i := 1; i < len(s); i++ {
+		for j := i; j > 0 && s[j] < s[j-1]; j-- {
+			s[j], s[j-1] = s[j-1], s[j]
+		}
+	}
+}
+
+func search(s []string, x string) int {
+	low, high := 0, len(s)
+	for low < high {
+		mid := (low + high) / 2
+		if s[mid] >= x {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low
+}
+
+func TestOrder() {
+	a, b := "abc", "abd"
+	empty := ""
+	prefix := "ab"
+	high := "\xff"
+	println(a < b, a <= b, a > b, a >= b)
+	println(a < a, a <= a, a > a, a >= a)
+	println(prefix < a, a < prefix, empty < prefix, empty <= empty)
+	println(high > a, "é" > "z", "Z" < "a")
+	x := []byte("abc")
+	println(string(x) == a, string(x) <= a, string(x[:2]) < a)
+}
+
+func TestSort() {
+	s := []string{"pear", "apple", "fig", "banana", "", "apple pie", "Cherry", "date"}
+	sortStrings(s)
+	for _, v := range s {
+		println(v)
+	}
+	println(search(s, "banana"), search(s, "coconut"), search(s, "zzz"), search(s, ""))
+}
+
+func main() {
+	TestOrder()
+	TestSort()
+}
@@ -0,0 +1,63 @@
+package main
+
+type I interface{ M() }
+
+type J interface {
+	M()
+	N()
+	A()
+}
+
+type T struct{}
+
+func (*T) M() {}
+
+func catch(f func()) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			println("no panic")
+			return
+		}
+		println(v.(error).Error())
+	}()
+	f()
+}
+
+func TestConcrete() {
+	var x interface{} = "a"
+	var y I = &T{}
+	var z I
+	catch(func() { _ = x.(int) })
+	catch(func() { _ = x.(string) })
+	catch(func() { _ = z.(*T) })
+	catch(func() { _ = y.(*T) })
+}
+
+func TestInterface() {
+	var x interface{} = "a"
+	var y I = &T{}
+	var z I
+	var e error
+	catch(func() { _ = x.(I) })
+	catch(func() { _ = y.(J) })
+	catch(func() { _ = z.(J) })
+	catch(func() { _ = y.(interface{ N() }) })
+	catch(func() { _ = y.(interface{ M() }) })
+	catch(func() { _ = e.(interface{ Error() string }) })
+}
+
+func TestCommaOk() {
+	var x interface{} = "a"
+	var y I = &T{}
+	_, ok1 := x.(int)
+	_, ok2 := y.(J)
+	_, ok3 := y.(interface{ M() })
+	println(ok1, ok2, ok3)
+}
+
+func main() {
+	TestConcrete()
+	TestInterface()
+	TestCommaOk()
+}
@@ -0,0 +1,118 @@
+package main
+
+type Writer interface {
+	Write(p []byte) (n int, err error)
+}
+
+type Stringer interface {
+	String() string
+}
+
+type BadWriter struct{}
+
+func (*BadWriter) Write(p []byte) int { return len(p) }
+
+type GoodWriter struct{}
+
+func (*GoodWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+type Name string
+
+func (n *Name) String() string { return string(*n) }
+
+type Number int
+
+func (n *Number) String() int { return int(*n) }
+
+type Handler interface {
+	Handle(f func(x int) int) int
+}
+
+type Double struct{}
+
+func (*Double) Handle(g func(int) int) int { return g(2) * 2 }
+
+func describe(v interface{}) string {
+	switch v.(type) {
+	case Writer:
+		return "writer"
+	case Stringer:
+		return "stringer"
+	case Handler:
+		return "handler"
+	default:
+		return "other"
+	}
+}
+
+func TestTypeSwitch() {
+	name := Name("a")
+	number := Number(1)
+	println(describe(&BadWriter{}))
+	println(describe(&GoodWriter{}))
+	println(describe(&name))
+	println(describe(&number))
+	println(describe(&Double{}))
+	println(describe(1))
+}
+
+func TestCommaOk() {
+	number := Number(1)
+	var v interface{} = &number
+	_, ok1 := v.(Stringer)
+	_, ok2 := v.(interface{ String() int })
+	_, ok3 := v.(interface{ String() string })
+	println(ok1, ok2, ok3)
+	var w interface{} = &GoodWriter{}
+	_, ok4 := w.(Writer)
+	_, ok5 := w.(interface{ Write([]byte) int })
+	println(ok4, ok5)
+}
+
+func TestInvoke() {
+	name := Name("b")
+	var h interface{} = &Double{}
+	println(h.(Handler).Handle(func(x int) int { return x + 1 }))
+	var s interface{} = &name
+	println(s.(Stringer).String())
+}
+
+func catch(f func()) {
+	defer func() {
+		println(recover().(error).Error())
+	}()
+	f()
+}
+
+func TestPanic() {
+	number := Number(1)
+	var v interface{} = &number
+	catch(func() { _ = v.(Stringer) })
+	var w interface{} = &BadWriter{}
+	catch(func() { _ = w.(Writer) })
+}
+
+type RuntimeError interface {
+	Error() (message string)
+	RuntimeError()
+}
+
+func TestRuntimeError() {
+	defer func() {
+		v := recover()
+		_, ok1 := v.(RuntimeError)
+		_, ok2 := v.(interface{ Error() int })
+		println(ok1, ok2, v.(RuntimeError).Error())
+	}()
+	s := []int{}
+	i := 1
+	s[i] = 0
+}
+
+func main() {
+	TestTypeSwitch()
+	TestCommaOk()
+	TestInvoke()
+	TestPanic()
+	TestRuntimeError()
+}